@@ -4,24 +4,102 @@ import (
 	"flag"
 	"fmt"
 	"github.com/3rf/codecoroner/unused"
-	"go/build"
 	"golang.org/x/tools/go/buildutil"
 	"os"
+	"path"
 	"sort"
 	"strings"
+	"text/tabwriter"
 )
 
 func main() {
-	var ignoreList string
+	var ignoreList, matrixList, rootsFile, format, tmpl, kindList, sortBy, baselineFile string
+	var summary, writeBaseline bool
 	ucf := unused.NewUnusedCodeFinder()
 	flag.BoolVar(&(ucf.Verbose), "v", false,
 		"prints extra information during execution to stderr")
 	flag.BoolVar(&(ucf.IncludeTests), "tests", false, "include tests in the analysis")
 	flag.StringVar(&(ignoreList), "ignore", "",
 		"don't read files that contain the given comma-separated strings (use to avoid /testdata, etc) ")
-	// hack for testing code with build flags
-	flag.Var((*buildutil.TagsFlag)(&build.Default.BuildTags), "tags", "a list of build tags")
+	flag.Var((*buildutil.TagsFlag)(&ucf.BuildTags), "tags", "a list of build tags")
+	flag.StringVar(&(ucf.Algorithm), "algo", unused.AlgorithmRTA,
+		"callgraph algorithm for 'funcs': "+strings.Join(unused.Algorithms, ", "))
+	flag.BoolVar(&(ucf.Library), "lib", false,
+		"treat the exported API as used, for analyzing libraries")
+	flag.StringVar(&(rootsFile), "roots", "",
+		"file listing extra entry points, one per line, like pkg.Func or (*pkg.T).Method")
+	flag.BoolVar(&(ucf.Workspace), "workspace", false,
+		"analyze every module in the current go.work file together, reporting per module")
+	flag.StringVar(&(matrixList), "matrix", "",
+		"analyze each of the given space-separated GOOS/GOARCH[/tags] configurations, "+
+			"reporting only code that is dead in all of them")
+	flag.StringVar(&(format), "format", "text",
+		"output format, one of: "+strings.Join(unused.ReporterNames(), ", "))
+	flag.StringVar(&(tmpl), "f", "",
+		"print each result with the given Go template, like 'go list -f'")
+	flag.StringVar(&(kindList), "kinds", "",
+		"only report the given comma-separated kinds: "+strings.Join(unused.Kinds, ", "))
+	flag.BoolVar(&(summary), "summary", false,
+		"print per-package dead code statistics instead of every result")
+	flag.StringVar(&(sortBy), "sort", "position",
+		"order of the results: 'position', or 'size' for the largest declarations first")
+	flag.StringVar(&(baselineFile), "baseline", "",
+		"JSON file of known findings; only findings not in it are reported, and fail the run")
+	flag.BoolVar(&(writeBaseline), "write-baseline", false,
+		"record the current findings in the -baseline file instead of reporting them")
+	flag.StringVar(&(ucf.Since), "since", "",
+		"only report dead code in lines changed since the given git ref, or made dead by those changes")
 	flag.Parse()
+	reporter, err := unused.NewReporter(format, os.Stdout)
+	if err != nil {
+		fmt.Printf("ERROR: %v; must be one of %v\n", err, strings.Join(unused.ReporterNames(), ", "))
+		os.Exit(2)
+	}
+	if tmpl != "" {
+		if format != "text" {
+			fmt.Println("ERROR: -f and -format cannot be used together")
+			os.Exit(2)
+		}
+		if reporter, err = unused.NewTemplateReporter(os.Stdout, tmpl); err != nil {
+			fmt.Println("ERROR: parsing -f template:", err)
+			os.Exit(2)
+		}
+	}
+	if summary && (format != "text" || tmpl != "") {
+		fmt.Println("ERROR: -summary cannot be used with -format or -f")
+		os.Exit(2)
+	}
+	if sortBy != "position" && sortBy != "size" {
+		fmt.Printf("ERROR: unknown -sort '%v'; must be 'position' or 'size'\n", sortBy)
+		os.Exit(2)
+	}
+	if writeBaseline && baselineFile == "" {
+		fmt.Println("ERROR: -write-baseline needs a -baseline file to write")
+		os.Exit(2)
+	}
+	kinds, err := unused.ParseKinds(kindList)
+	if err != nil {
+		fmt.Println("ERROR:", err)
+		os.Exit(2)
+	}
+	// handle build configuration matrix
+	for _, config := range strings.Fields(matrixList) {
+		bc, err := unused.ParseBuildConfig(config)
+		if err != nil {
+			fmt.Println("ERROR:", err)
+			os.Exit(2)
+		}
+		ucf.Matrix = append(ucf.Matrix, bc)
+	}
+	// handle extra roots
+	if rootsFile != "" {
+		roots, err := unused.ReadRootsFile(rootsFile)
+		if err != nil {
+			fmt.Println("ERROR: reading roots file:", err)
+			os.Exit(2)
+		}
+		ucf.Roots = roots
+	}
 	// handle ignore list
 	ucf.Ignore = strings.Split(ignoreList, ",")
 	if len(ucf.Ignore) > 0 && ucf.Ignore[0] == "" {
@@ -29,7 +107,7 @@ func main() {
 	}
 
 	if len(flag.Args()) == 0 {
-		fmt.Println("Must specify either 'funcs' or 'idents' command. Run with -help for more info.")
+		fmt.Println("Must specify either 'funcs', 'idents', 'ssa', 'whylive', 'impact' or 'binaries' command. Run with -help for more info.")
 		os.Exit(2)
 	}
 	command := flag.Arg(0)
@@ -38,8 +116,19 @@ func main() {
 		ucf.Idents = false
 	case "idents", "identifiers":
 		ucf.Idents = true
+	case "ssa":
+		ucf.SSA = true
+	case "whylive":
+		whyLive(ucf)
+		return
+	case "impact":
+		impact(ucf)
+		return
+	case "binaries":
+		binaries(ucf)
+		return
 	default:
-		fmt.Println("Must specify either 'funcs' or 'idents' command. Run with -help for more info.")
+		fmt.Println("Must specify either 'funcs', 'idents', 'ssa', 'whylive', 'impact' or 'binaries' command. Run with -help for more info.")
 		os.Exit(2)
 	}
 
@@ -49,9 +138,142 @@ func main() {
 		os.Exit(1)
 	}
 	ucf.Logf("") // ensure a newline before printing results if -v is on
-
+	if len(kinds) > 0 {
+		unusedObjects = unused.FilterKinds(unusedObjects, kinds)
+	}
 	sort.Sort(unused.ByPosition(unusedObjects))
-	for _, o := range unusedObjects {
+
+	// handle the baseline
+	if writeBaseline {
+		if err := unused.NewBaseline(unusedObjects).Write(baselineFile); err != nil {
+			fmt.Println("ERROR: writing baseline:", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Recorded %v findings in %v\n", len(unusedObjects), baselineFile)
+		return
+	}
+	if baselineFile != "" {
+		baseline, err := unused.ReadBaseline(baselineFile)
+		if err != nil {
+			fmt.Println("ERROR: reading baseline:", err)
+			os.Exit(2)
+		}
+		unusedObjects = baseline.NewFindings(unusedObjects)
+	}
+
+	if summary {
+		printSummary(ucf.Summarize(unusedObjects))
+	} else {
+		if sortBy == "size" {
+			sort.Sort(unused.BySize(unusedObjects))
+		}
+		info := unused.ReportInfo{
+			Mode:      ucf.Mode(),
+			Packages:  ucf.Packages(),
+			Workspace: ucf.Workspace,
+		}
+		if err := unused.WriteReport(reporter, info, unusedObjects); err != nil {
+			fmt.Println("ERROR:", err)
+			os.Exit(1)
+		}
+	}
+	// with a baseline, any new finding fails the run
+	if baselineFile != "" && len(unusedObjects) > 0 {
+		os.Exit(1)
+	}
+}
+
+// whyLive prints the call chain that keeps the symbol given after
+// the 'whylive' command alive
+func whyLive(ucf *unused.UnusedCodeFinder) {
+	if len(flag.Args()) < 2 {
+		fmt.Println("Must specify a function for 'whylive', as in 'whylive pkg.Func ./...'.")
+		os.Exit(2)
+	}
+	symbol := flag.Arg(1)
+	chain, err := ucf.WhyLive(symbol, flag.Args()[2:])
+	if err != nil {
+		fmt.Println("ERROR:", err)
+		os.Exit(1)
+	}
+	ucf.Logf("")
+	if len(chain) == 0 {
+		fmt.Printf("%v is a root\n", symbol)
+	}
+	for _, edge := range chain {
+		fmt.Printf("%s\n", edge)
+	}
+}
+
+// impact prints what would become dead if the comma-separated
+// functions or main packages given after 'impact' were deleted
+func impact(ucf *unused.UnusedCodeFinder) {
+	if len(flag.Args()) < 2 {
+		fmt.Println("Must specify functions or main packages for 'impact', as in 'impact pkg.Func,./cmd/tool ./...'.")
+		os.Exit(2)
+	}
+	report, err := ucf.Impact(strings.Split(flag.Arg(1), ","), flag.Args()[2:])
+	if err != nil {
+		fmt.Println("ERROR:", err)
+		os.Exit(1)
+	}
+	ucf.Logf("")
+	sort.Sort(unused.ByPosition(report.Dead))
+	for _, o := range report.Dead {
 		fmt.Printf("%s\n", o)
 	}
+	fmt.Printf("%v, %v would become dead\n",
+		unused.Count(len(report.Dead), "declaration"), unused.Count(report.Lines, "line"))
+}
+
+// binaries prints which main packages reach each function, as a table
+// with a column per binary, pointing out the functions only one reaches
+func binaries(ucf *unused.UnusedCodeFinder) {
+	matrix, err := ucf.BinaryMatrix(flag.Args()[1:])
+	if err != nil {
+		fmt.Println("ERROR:", err)
+		os.Exit(1)
+	}
+	ucf.Logf("")
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprint(w, "FUNCTION")
+	for _, bin := range matrix.Binaries {
+		fmt.Fprintf(w, "\t%s", path.Base(bin))
+	}
+	fmt.Fprintln(w)
+	for _, row := range matrix.Rows {
+		fmt.Fprintf(w, "%s", row)
+		for _, reached := range row.ReachedBy {
+			if reached {
+				fmt.Fprint(w, "\tx")
+			} else {
+				fmt.Fprint(w, "\t-")
+			}
+		}
+		// with a single binary, everything would be highlighted
+		if only := row.Only(); only >= 0 && len(matrix.Binaries) > 1 {
+			fmt.Fprintf(w, "\tonly %s", path.Base(matrix.Binaries[only]))
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+}
+
+func printSummary(summaries []unused.PackageSummary) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tDEAD\tDECLS\tDEAD LINES\tLINES\tDEAD %\tKINDS")
+	for _, s := range summaries {
+		kinds := []string{}
+		for _, kind := range unused.Kinds {
+			if n := s.Dead[kind]; n > 0 {
+				kinds = append(kinds, fmt.Sprintf("%v %v", n, kind))
+			}
+		}
+		if len(kinds) == 0 {
+			kinds = append(kinds, "-")
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%.1f%%\t%s\n", s.Package,
+			s.DeadDecls, s.Decls, s.DeadLines, s.Lines, s.DeadPercent(), strings.Join(kinds, ", "))
+	}
+	w.Flush()
 }
@@ -0,0 +1,118 @@
+package unused
+
+import (
+	"fmt"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/callgraph/rta"
+	"golang.org/x/tools/go/callgraph/vta"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// The call graph algorithms that can be used for 'funcs' analysis,
+// roughly ordered from fastest and most conservative to slowest and
+// most precise.
+const (
+	AlgorithmCHA = "cha"
+	AlgorithmRTA = "rta"
+	AlgorithmVTA = "vta"
+)
+
+// Algorithms lists every supported call graph algorithm
+var Algorithms = []string{AlgorithmCHA, AlgorithmRTA, AlgorithmVTA}
+
+// algorithmPointer names the pointer analysis, which golang.org/x/tools
+// no longer provides since go/pointer was removed from it
+const algorithmPointer = "pointer"
+
+// analyze builds a call graph for the program with the finder's chosen
+// algorithm and returns it along with the set of reachable functions.
+func (ucf *UnusedCodeFinder) analyze(prog *ssa.Program,
+	roots []*ssa.Function) (*callgraph.Graph, map[*ssa.Function]bool, error) {
+
+	var cg *callgraph.Graph
+	switch ucf.Algorithm {
+	case AlgorithmCHA:
+		cg = cha.CallGraph(prog)
+	case AlgorithmRTA, "":
+		res := rta.Analyze(roots, true)
+		// RTA already knows what is reachable, so use that directly
+		reachable := map[*ssa.Function]bool{}
+		for fn := range res.Reachable {
+			reachable[fn] = true
+		}
+		return res.CallGraph, reachable, nil
+	case AlgorithmVTA:
+		// VTA refines an initial, conservative call graph
+		cg = vta.CallGraph(ssautil.AllFunctions(prog), cha.CallGraph(prog))
+	case algorithmPointer:
+		return nil, nil, fmt.Errorf("the pointer callgraph algorithm is not supported, " +
+			"since go/pointer was removed from golang.org/x/tools; use vta instead")
+	default:
+		return nil, nil, fmt.Errorf("unknown callgraph algorithm %q", ucf.Algorithm)
+	}
+	addMissingRoots(cg, roots)
+	return cg, reachableFrom(cg, roots, nil), nil
+}
+
+// addMissingRoots adds the roots a whole-program call graph leaves out,
+// like the uninstantiated methods of generic types, along with edges to
+// the functions they call statically
+func addMissingRoots(cg *callgraph.Graph, roots []*ssa.Function) {
+	queue := []*ssa.Function{}
+	for _, root := range roots {
+		if root != nil && cg.Nodes[root] == nil {
+			cg.CreateNode(root)
+			queue = append(queue, root)
+		}
+	}
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		node := cg.Nodes[fn]
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				site, ok := instr.(ssa.CallInstruction)
+				if !ok {
+					continue
+				}
+				callee := site.Common().StaticCallee()
+				if callee == nil {
+					continue
+				}
+				if cg.Nodes[callee] == nil {
+					queue = append(queue, callee)
+				}
+				callgraph.AddEdge(node, site, cg.CreateNode(callee))
+			}
+		}
+	}
+}
+
+// reachableFrom walks the call graph outward from the roots, never
+// entering the functions that were cut out of it
+func reachableFrom(cg *callgraph.Graph, roots []*ssa.Function,
+	cut map[*ssa.Function]bool) map[*ssa.Function]bool {
+
+	reachable := map[*ssa.Function]bool{}
+	queue := []*callgraph.Node{}
+	for _, root := range roots {
+		if node := cg.Nodes[root]; node != nil && !reachable[root] && !cut[root] {
+			reachable[root] = true
+			queue = append(queue, node)
+		}
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, edge := range node.Out {
+			if callee := edge.Callee.Func; !reachable[callee] && !cut[callee] {
+				reachable[callee] = true
+				queue = append(queue, edge.Callee)
+			}
+		}
+	}
+	return reachable
+}
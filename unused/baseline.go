@@ -0,0 +1,90 @@
+package unused
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// baselineVersion is the version of the baseline file format
+const baselineVersion = 1
+
+// Baseline records the findings of an earlier run, so that later runs
+// can report only the findings that are new since then
+type Baseline struct {
+	Version  int             `json:"version"`
+	Findings []BaselineEntry `json:"findings"`
+}
+
+// BaselineEntry is a single recorded finding. Only the fingerprint is
+// used for matching; the rest is there to make the file readable.
+type BaselineEntry struct {
+	Fingerprint string `json:"fingerprint"`
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	Package     string `json:"package"`
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+}
+
+// NewBaseline records the given findings
+func NewBaseline(unusedObjects []UnusedObject) *Baseline {
+	b := &Baseline{Version: baselineVersion, Findings: []BaselineEntry{}}
+	for _, o := range unusedObjects {
+		b.Findings = append(b.Findings, BaselineEntry{
+			Fingerprint: o.Fingerprint(),
+			Name:        o.QualifiedName(),
+			Kind:        o.Kind,
+			Package:     o.Package,
+			File:        o.File(),
+			Line:        o.Position.Line,
+		})
+	}
+	return b
+}
+
+// ReadBaseline reads a baseline file written by Baseline.Write
+func ReadBaseline(filename string) (*Baseline, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	b := &Baseline{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("error parsing baseline %v: %v", filename, err)
+	}
+	if b.Version != baselineVersion {
+		return nil, fmt.Errorf("baseline %v has unsupported version %v", filename, b.Version)
+	}
+	return b, nil
+}
+
+// Write writes the baseline to a file as indented JSON, which
+// keeps the diffs of a checked-in baseline readable
+func (b *Baseline) Write(filename string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+// NewFindings returns the objects that aren't in the baseline, in order.
+// Fingerprints don't have to be unique, like two dead fields with the
+// same name in one package, so each recorded finding only accounts for
+// a single object.
+func (b *Baseline) NewFindings(unusedObjects []UnusedObject) []UnusedObject {
+	recorded := map[string]int{}
+	for _, entry := range b.Findings {
+		recorded[entry.Fingerprint]++
+	}
+	found := []UnusedObject{}
+	for _, o := range unusedObjects {
+		if fp := o.Fingerprint(); recorded[fp] > 0 {
+			recorded[fp]--
+			continue
+		}
+		found = append(found, o)
+	}
+	return found
+}
@@ -0,0 +1,134 @@
+package unused
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	Convey("with the results of running 'ssa' on a test main package", t, func() {
+		ucf := NewUnusedCodeFinder()
+		ucf.SSA = true
+		results, err := ucf.Run(testdataPkgs)
+		So(err, ShouldBeNil)
+		So(len(results), ShouldBeGreaterThan, 1)
+
+		Convey("a baseline written to disk and read back", func() {
+			dir, err := ioutil.TempDir("", "baseline")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+			filename := filepath.Join(dir, "baseline.json")
+			So(NewBaseline(results).Write(filename), ShouldBeNil)
+			baseline, err := ReadBaseline(filename)
+			So(err, ShouldBeNil)
+			So(len(baseline.Findings), ShouldEqual, len(results))
+
+			Convey("should match every recorded finding", func() {
+				So(baseline.NewFindings(results), ShouldBeEmpty)
+			})
+
+			Convey("even when the declarations have moved", func() {
+				moved := append([]UnusedObject{}, results...)
+				for i := range moved {
+					moved[i].Position.Line += 10
+				}
+				So(baseline.NewFindings(moved), ShouldBeEmpty)
+			})
+
+			Convey("but not findings that weren't recorded", func() {
+				old := NewBaseline(results[1:])
+				found := old.NewFindings(results)
+				So(len(found), ShouldEqual, 1)
+				So(found[0].Name, ShouldEqual, results[0].Name)
+			})
+
+			Convey("and each recorded finding should only match once", func() {
+				found := baseline.NewFindings(append(results, results[0]))
+				So(len(found), ShouldEqual, 1)
+			})
+		})
+
+		Convey("a baseline of another version should be rejected", func() {
+			dir, err := ioutil.TempDir("", "baseline")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+			filename := filepath.Join(dir, "baseline.json")
+			So(ioutil.WriteFile(filename, []byte(`{"version":2,"findings":[]}`), 0644), ShouldBeNil)
+			_, err = ReadBaseline(filename)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestBaselineQualifiedNames(t *testing.T) {
+	Convey("with a package declaring fields and parameters of the same name", t, func() {
+		dir, err := ioutil.TempDir("", "qualified")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		So(writeFiles(dir, map[string]string{
+			"go.mod": "module example.com/qualified\n",
+			"main.go": `package main
+
+type A struct{ ID int }
+
+type B struct{ ID int }
+
+func f(ctx int) {}
+
+func g(ctx int) {}
+
+func main() {
+	f(0)
+	g(0)
+	_, _ = A{}, B{}
+}
+`,
+		}), ShouldBeNil)
+
+		byQualifiedName := func(results []UnusedObject) map[string]UnusedObject {
+			found := map[string]UnusedObject{}
+			for _, o := range results {
+				found[o.QualifiedName()] = o
+			}
+			return found
+		}
+
+		for _, mode := range []string{"idents", "ssa"} {
+			mode := mode
+			Convey("running '"+mode+"'", func() {
+				ucf := NewUnusedCodeFinder()
+				ucf.Dir = dir
+				ucf.Idents = mode == "idents"
+				ucf.SSA = mode == "ssa"
+				results, err := ucf.Run([]string{"."})
+				So(err, ShouldBeNil)
+				found := byQualifiedName(results)
+
+				Convey("should qualify fields with their type", func() {
+					So(found, ShouldContainKey, "A.ID")
+					So(found, ShouldContainKey, "B.ID")
+					So(found["A.ID"].Fingerprint(), ShouldNotEqual, found["B.ID"].Fingerprint())
+				})
+
+				Convey("and a baseline of one field shouldn't hide the other", func() {
+					baseline := NewBaseline([]UnusedObject{found["A.ID"]})
+					So(baseline.Findings[0].Name, ShouldEqual, "A.ID")
+					fresh := byQualifiedName(baseline.NewFindings(results))
+					So(fresh, ShouldNotContainKey, "A.ID")
+					So(fresh, ShouldContainKey, "B.ID")
+				})
+
+				if mode == "idents" {
+					Convey("and qualify parameters with their function", func() {
+						So(found, ShouldContainKey, "f.ctx")
+						So(found, ShouldContainKey, "g.ctx")
+						So(found["f.ctx"].Fingerprint(), ShouldNotEqual, found["g.ctx"].Fingerprint())
+					})
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package unused
+
+import (
+	"go/token"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// BinaryMatrix records which binaries (main packages) reach each
+// function, for repositories that build more than one command
+type BinaryMatrix struct {
+	// Binaries holds the import paths of the main packages, sorted
+	Binaries []string
+	Rows     []BinaryRow
+}
+
+// BinaryRow is a function along with the binaries that reach it
+type BinaryRow struct {
+	UnusedObject
+	// ReachedBy[i] is set if Binaries[i] reaches the function
+	ReachedBy []bool
+}
+
+// Only returns the index of the single binary that reaches the function,
+// or -1 if more than one does
+func (r BinaryRow) Only() int {
+	only := -1
+	for i, reached := range r.ReachedBy {
+		if reached {
+			if only >= 0 {
+				return -1
+			}
+			only = i
+		}
+	}
+	return only
+}
+
+// BinaryMatrix runs the callgraph analysis separately from the roots of
+// each main package, instead of merging them into one root set, so
+// dynamic calls only resolve to the types that binary creates. Only
+// functions that at least one binary reaches are included.
+func (ucf *UnusedCodeFinder) BinaryMatrix(patterns []string) (*BinaryMatrix, error) {
+	if err := ucf.load(patterns); err != nil {
+		return nil, err
+	}
+	ucf.Logf("Running callgraph analysis on following packages: \n\t%v",
+		strings.Join(ucf.pkgsAsArray(), "\n\t"))
+	prog, mains, err := ucf.buildProgram()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(mains, func(i, j int) bool {
+		return mains[i].Pkg.Path() < mains[j].Pkg.Path()
+	})
+	matrix := &BinaryMatrix{}
+	reachedBy := map[token.Position][]bool{}
+	for i, main := range mains {
+		matrix.Binaries = append(matrix.Binaries, main.Pkg.Path())
+		ucf.Logf("Building callgraph for %v with %v", main.Pkg.Path(), ucf.algorithm())
+		roots := []*ssa.Function{main.Func("init"), main.Func("main")}
+		_, reachable, err := ucf.analyze(prog, roots)
+		if err != nil {
+			return nil, err
+		}
+		for fn := range reachable {
+			key, ok := ucf.funcKey(fn)
+			if !ok {
+				continue
+			}
+			if reachedBy[key] == nil {
+				reachedBy[key] = make([]bool, len(mains))
+			}
+			reachedBy[key][i] = true
+		}
+	}
+
+	for _, f := range ucf.funcs {
+		if reached, ok := reachedBy[f.key]; ok {
+			matrix.Rows = append(matrix.Rows, BinaryRow{f.UnusedObject, reached})
+		}
+	}
+	sort.Slice(matrix.Rows, func(i, j int) bool {
+		return ByPosition{matrix.Rows[i].UnusedObject, matrix.Rows[j].UnusedObject}.Less(0, 1)
+	})
+	return matrix, nil
+}
@@ -0,0 +1,49 @@
+package unused
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestBinaryMatrix(t *testing.T) {
+	Convey("with two main packages sharing a library and a UnusedCodeFinder", t, func() {
+		ucf := NewUnusedCodeFinder()
+		So(ucf, ShouldNotBeNil)
+
+		Convey("building the per-binary matrix", func() {
+			matrix, err := ucf.BinaryMatrix([]string{"./testdata/bins/..."})
+			So(err, ShouldBeNil)
+			So(matrix.Binaries, ShouldResemble, []string{
+				"github.com/3rf/codecoroner/unused/testdata/bins/alpha",
+				"github.com/3rf/codecoroner/unused/testdata/bins/beta",
+			})
+			rows := map[string]BinaryRow{}
+			for _, row := range matrix.Rows {
+				rows[row.Name] = row
+			}
+
+			Convey("shared code should be reached by both binaries", func() {
+				So(rows["Greet"].ReachedBy, ShouldResemble, []bool{true, true})
+				So(rows["salutation"].ReachedBy, ShouldResemble, []bool{true, true})
+				So(rows["Greet"].Only(), ShouldEqual, -1)
+			})
+
+			Convey("code only one binary uses should be attributed to it", func() {
+				So(rows["Legacy"].ReachedBy, ShouldResemble, []bool{true, false})
+				So(rows["Legacy"].Only(), ShouldEqual, 0)
+				So(rows["Fresh"].Only(), ShouldEqual, 1)
+			})
+
+			Convey("dynamic calls should only reach the types each binary creates", func() {
+				So(rows["Announce"].ReachedBy, ShouldResemble, []bool{true, true})
+				So(rows["(Loud).Speak"].ReachedBy, ShouldResemble, []bool{true, false})
+				So(rows["(Quiet).Speak"].ReachedBy, ShouldResemble, []bool{false, true})
+			})
+
+			Convey("and dead code should be left out", func() {
+				_, ok := rows["Unused"]
+				So(ok, ShouldBeFalse)
+			})
+		})
+	})
+}
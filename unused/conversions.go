@@ -0,0 +1,231 @@
+package unused
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// conversionVisitor collects the concrete types a declaration converts
+// to interfaces, keeping track of the results of the function around
+// each return statement
+type conversionVisitor struct {
+	info    *types.Info
+	results *types.Tuple
+	found   *[]types.Type
+}
+
+// convertedTypes returns the concrete types whose values a declaration
+// converts to an interface, such as by passing them to fmt.Println
+func convertedTypes(info *types.Info, d ast.Decl) []types.Type {
+	found := []types.Type{}
+	ast.Walk(conversionVisitor{info: info, found: &found}, d)
+	return found
+}
+
+// convert records the type of expr if assigning it to a value of type
+// to converts it to an interface
+func (cv conversionVisitor) convert(to types.Type, expr ast.Expr) {
+	if to == nil || !types.IsInterface(to) {
+		return
+	}
+	tv, ok := cv.info.Types[expr]
+	if !ok || !tv.IsValue() || tv.Type == nil || types.IsInterface(tv.Type) {
+		return
+	}
+	*cv.found = append(*cv.found, tv.Type)
+}
+
+func (cv conversionVisitor) Visit(n ast.Node) ast.Visitor {
+	switch n := n.(type) {
+	case *ast.FuncDecl:
+		if obj := cv.info.Defs[n.Name]; obj != nil {
+			if sig, ok := obj.Type().(*types.Signature); ok {
+				cv.results = sig.Results()
+			}
+		}
+	case *ast.FuncLit:
+		if sig, ok := cv.info.TypeOf(n).(*types.Signature); ok {
+			cv.results = sig.Results()
+		}
+	case *ast.CallExpr:
+		cv.call(n)
+	case *ast.AssignStmt:
+		if len(n.Lhs) == len(n.Rhs) {
+			for i := range n.Lhs {
+				cv.convert(cv.info.TypeOf(n.Lhs[i]), n.Rhs[i])
+			}
+		}
+	case *ast.ValueSpec:
+		if n.Type != nil && len(n.Names) == len(n.Values) {
+			for _, value := range n.Values {
+				cv.convert(cv.info.TypeOf(n.Type), value)
+			}
+		}
+	case *ast.ReturnStmt:
+		if cv.results != nil && len(n.Results) == cv.results.Len() {
+			for i, result := range n.Results {
+				cv.convert(cv.results.At(i).Type(), result)
+			}
+		}
+	case *ast.SendStmt:
+		if ch, ok := underlying(cv.info.TypeOf(n.Chan)).(*types.Chan); ok {
+			cv.convert(ch.Elem(), n.Value)
+		}
+	case *ast.CompositeLit:
+		cv.compositeLit(n)
+	}
+	return cv
+}
+
+// call records the arguments converted to interface parameters, and
+// explicit conversions like any(x)
+func (cv conversionVisitor) call(call *ast.CallExpr) {
+	tv, ok := cv.info.Types[call.Fun]
+	if !ok {
+		return
+	}
+	if tv.IsType() {
+		if len(call.Args) == 1 {
+			cv.convert(tv.Type, call.Args[0])
+		}
+		return
+	}
+	sig, ok := underlying(tv.Type).(*types.Signature)
+	if !ok {
+		return
+	}
+	params := sig.Params()
+	for i, arg := range call.Args {
+		switch {
+		case sig.Variadic() && i >= params.Len()-1:
+			if call.Ellipsis.IsValid() {
+				// the slice is passed as is
+				return
+			}
+			if slice, ok := params.At(params.Len() - 1).Type().(*types.Slice); ok {
+				cv.convert(slice.Elem(), arg)
+			}
+		case i < params.Len():
+			cv.convert(params.At(i).Type(), arg)
+		}
+	}
+}
+
+// compositeLit records the elements converted to interface fields,
+// elements, keys or values
+func (cv conversionVisitor) compositeLit(lit *ast.CompositeLit) {
+	switch t := underlying(cv.info.TypeOf(lit)).(type) {
+	case *types.Struct:
+		for i, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				if key, ok := kv.Key.(*ast.Ident); ok {
+					if field, ok := cv.info.Uses[key].(*types.Var); ok {
+						cv.convert(field.Type(), kv.Value)
+					}
+				}
+			} else if i < t.NumFields() {
+				cv.convert(t.Field(i).Type(), elt)
+			}
+		}
+	case *types.Slice, *types.Array, *types.Map:
+		var key, elem types.Type
+		switch t := t.(type) {
+		case *types.Slice:
+			elem = t.Elem()
+		case *types.Array:
+			elem = t.Elem()
+		case *types.Map:
+			key, elem = t.Key(), t.Elem()
+		}
+		for _, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				cv.convert(key, kv.Key)
+				elt = kv.Value
+			}
+			cv.convert(elem, elt)
+		}
+	}
+}
+
+// underlying is t's underlying type, or nil for a nil type
+func underlying(t types.Type) types.Type {
+	if t == nil {
+		return nil
+	}
+	return t.Underlying()
+}
+
+// interfaceTypes returns the interfaces with methods that the packages
+// or their dependencies declare or use, such as fmt.Stringer, which a
+// converted value's methods can be called through
+func interfaceTypes(pkgs []*packages.Package) []*types.Interface {
+	seen := map[*types.Interface]bool{}
+	ifaces := []*types.Interface{}
+	add := func(t types.Type) {
+		if named, ok := t.(*types.Named); ok && named.TypeParams().Len() > 0 {
+			// generic interfaces only mean something instantiated
+			return
+		}
+		iface, ok := underlying(t).(*types.Interface)
+		if !ok || seen[iface] || iface.NumMethods() == 0 || !iface.IsMethodSet() {
+			return
+		}
+		seen[iface] = true
+		ifaces = append(ifaces, iface)
+	}
+	add(types.Universe.Lookup("error").Type())
+
+	visited := map[*types.Package]bool{}
+	var visit func(pkg *types.Package)
+	visit = func(pkg *types.Package) {
+		if pkg == nil || visited[pkg] {
+			return
+		}
+		visited[pkg] = true
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			if tn, ok := scope.Lookup(name).(*types.TypeName); ok {
+				add(tn.Type())
+			}
+		}
+		for _, imp := range pkg.Imports() {
+			visit(imp)
+		}
+	}
+	for _, pkg := range pkgs {
+		visit(pkg.Types)
+		if pkg.TypesInfo != nil {
+			// interfaces without a name, as in x.(interface{ Close() error })
+			for _, tv := range pkg.TypesInfo.Types {
+				if tv.Type != nil && types.IsInterface(tv.Type) {
+					add(tv.Type)
+				}
+			}
+		}
+	}
+	return ifaces
+}
+
+// interfaceMethods returns the methods of t that are part of an
+// interface t implements, so converting a t to an interface may call them
+func (ucf *UnusedCodeFinder) interfaceMethods(t types.Type, ifaces []*types.Interface) []ident {
+	if _, ok := t.(*types.TypeParam); ok {
+		return nil
+	}
+	methods := []ident{}
+	for _, iface := range ifaces {
+		if !types.Implements(t, iface) {
+			continue
+		}
+		for i := 0; i < iface.NumMethods(); i++ {
+			m := iface.Method(i)
+			obj, _, _ := types.LookupFieldOrMethod(t, false, m.Pkg(), m.Name())
+			if f, ok := obj.(*types.Func); ok {
+				methods = append(methods, ucf.identFor(f.Origin()))
+			}
+		}
+	}
+	return methods
+}
@@ -0,0 +1,221 @@
+package unused
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// declaration is a single package-level declaration (or struct field)
+// of an analyzed package, along with the positions of every object its
+// syntax refers to. Declarations and references are keyed by position
+// rather than types.Object, since the test variant of a package declares
+// its own copy of every object.
+type declaration struct {
+	Name string
+	Pos  token.Position
+	Obj  types.Object
+	Node ast.Node
+	Pkg  *packages.Package
+	Refs []token.Position
+	// Scope is the type declaring a struct field
+	Scope string
+}
+
+// declObject describes a declaration as an unused object
+func (ucf *UnusedCodeFinder) declObject(d *declaration) UnusedObject {
+	o := ucf.newObject(d.Pkg, d.Name, kindOf(d.Obj), d.Obj.Pos(), d.Node)
+	o.scope = d.Scope
+	return o
+}
+
+// isFunc reports whether the declaration is a function or method,
+// whose liveness comes from the callgraph instead of references
+func (d *declaration) isFunc() bool {
+	_, ok := d.Obj.(*types.Func)
+	return ok
+}
+
+// collectDeclarationGraph gathers the package-level declarations of
+// every analyzed file, keyed by the position of the declared name.
+func (ucf *UnusedCodeFinder) collectDeclarationGraph() map[token.Position]*declaration {
+	decls := map[token.Position]*declaration{}
+	for _, pkg := range ucf.targets {
+		if isTestMain(pkg) || pkg.TypesInfo == nil {
+			continue
+		}
+		for _, f := range ucf.sourceFiles(pkg) {
+			if ucf.shouldIgnorePath(ucf.sourceName(f)) {
+				continue
+			}
+			for _, d := range f.Decls {
+				ucf.addDeclarations(decls, pkg, d)
+			}
+		}
+	}
+	return decls
+}
+
+func (ucf *UnusedCodeFinder) addDeclarations(
+	decls map[token.Position]*declaration, pkg *packages.Package, d ast.Decl) {
+
+	add := func(name *ast.Ident, node ast.Node, refs []token.Position, scope string) {
+		obj := pkg.TypesInfo.Defs[name]
+		if obj == nil {
+			return
+		}
+		pos := ucf.fset.Position(obj.Pos())
+		if _, ok := decls[pos]; ok {
+			return
+		}
+		declName := obj.Name()
+		if f, ok := objToFunc(obj); ok {
+			declName = handleMethodName(f)
+		}
+		decls[pos] = &declaration{
+			Name:  declName,
+			Pos:   pos,
+			Obj:   obj,
+			Node:  node,
+			Pkg:   pkg,
+			Refs:  refs,
+			Scope: scope,
+		}
+	}
+
+	switch d := d.(type) {
+	case *ast.FuncDecl:
+		add(d.Name, d, ucf.references(pkg.TypesInfo, d), "")
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			refs := ucf.references(pkg.TypesInfo, spec)
+			switch spec := spec.(type) {
+			case *ast.ValueSpec:
+				for _, name := range spec.Names {
+					add(name, spec, refs, "")
+				}
+			case *ast.TypeSpec:
+				add(spec.Name, spec, refs, "")
+				// struct fields are declarations of their own, but only
+				// live if something refers to them
+				ast.Inspect(spec.Type, func(n ast.Node) bool {
+					switch n := n.(type) {
+					case *ast.Field:
+						for _, name := range n.Names {
+							add(name, n, nil, spec.Name.Name)
+						}
+						if len(n.Names) == 0 {
+							if name := embeddedIdent(n); name != nil {
+								add(name, n, nil, spec.Name.Name)
+							}
+						}
+					case *ast.InterfaceType, *ast.FuncType:
+						// methods and parameters aren't struct fields
+						return false
+					}
+					return true
+				})
+			}
+		}
+	}
+}
+
+// skipDeclName filters out declarations that are never dead code
+func skipDeclName(name string) bool {
+	return name == "_" ||
+		name == "main" ||
+		name == "init" ||
+		strings.HasPrefix(name, "Test") ||
+		strings.HasPrefix(name, "Benchmark") ||
+		strings.HasPrefix(name, "Example") ||
+		strings.HasPrefix(name, "Fuzz")
+}
+
+// embeddedIdent returns the identifier that names an embedded field
+func embeddedIdent(field *ast.Field) *ast.Ident {
+	typ := field.Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	switch t := typ.(type) {
+	case *ast.IndexExpr:
+		typ = t.X
+	case *ast.IndexListExpr:
+		typ = t.X
+	}
+	switch t := typ.(type) {
+	case *ast.Ident:
+		return t
+	case *ast.SelectorExpr:
+		return t.Sel
+	}
+	return nil
+}
+
+// references returns the positions of every object used within node,
+// including struct fields that are only used implicitly, either through
+// promotion or by unkeyed composite literals
+func (ucf *UnusedCodeFinder) references(info *types.Info, node ast.Node) []token.Position {
+	refs := []token.Position{}
+	addRef := func(obj types.Object) {
+		if obj != nil && obj.Pkg() != nil && obj.Pos().IsValid() {
+			refs = append(refs, ucf.fset.Position(obj.Pos()))
+		}
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			addRef(origin(info.Uses[n]))
+		case *ast.SelectorExpr:
+			// promoted fields and methods use every embedded field on the way
+			if sel := info.Selections[n]; sel != nil {
+				t := sel.Recv()
+				for _, idx := range sel.Index()[:len(sel.Index())-1] {
+					st, ok := derefStruct(t)
+					if !ok {
+						break
+					}
+					field := st.Field(idx)
+					addRef(field.Origin())
+					t = field.Type()
+				}
+			}
+		case *ast.CompositeLit:
+			if tv, ok := info.Types[n]; ok && len(n.Elts) > 0 {
+				if _, keyed := n.Elts[0].(*ast.KeyValueExpr); !keyed {
+					if st, ok := derefStruct(tv.Type); ok {
+						for i := 0; i < st.NumFields(); i++ {
+							addRef(st.Field(i).Origin())
+						}
+					}
+				}
+			}
+		}
+		return true
+	})
+	return refs
+}
+
+// origin maps objects of instantiated generic code back to the
+// objects that were actually declared
+func origin(obj types.Object) types.Object {
+	switch obj := obj.(type) {
+	case *types.Func:
+		return obj.Origin()
+	case *types.Var:
+		return obj.Origin()
+	}
+	return obj
+}
+
+// derefStruct returns the struct underlying t or *t
+func derefStruct(t types.Type) (*types.Struct, bool) {
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	return st, ok
+}
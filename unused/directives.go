@@ -0,0 +1,268 @@
+package unused
+
+import (
+	"bufio"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+)
+
+// keepDirective marks a declaration as intentionally kept, e.g. a function
+// called through plugin.Lookup or a field filled in by reflection
+const keepDirective = "//codecoroner:keep"
+
+// directives that mark a function as called from outside of Go
+const (
+	exportDirective     = "//export"
+	wasmexportDirective = "//go:wasmexport"
+	linknameDirective   = "//go:linkname"
+)
+
+// keptDecl is a declaration that is never reported and keeps alive
+// everything it refers to. The node is nil for linkname targets, since
+// those are found by name instead of by syntax.
+type keptDecl struct {
+	node ast.Node
+	info *types.Info
+}
+
+// ReadRootsFile reads a list of extra entry points, one per line, like
+// "pkg.Func" or "(*pkg.T).Method". Packages may be given by name or by
+// full import path. Blank lines and lines starting with '#' are skipped.
+func ReadRootsFile(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	roots := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		roots = append(roots, line)
+	}
+	return roots, scanner.Err()
+}
+
+// hasDirective reports whether a comment group contains the directive
+func hasDirective(doc *ast.CommentGroup, directive string) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if c.Text == directive || strings.HasPrefix(c.Text, directive+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// readDirectives records the declarations of a file that are kept, either
+// by a keep directive, by being listed in the roots file, or by being
+// called from outside of Go (cgo exports, wasm exports and linknames)
+func (ucf *UnusedCodeFinder) readDirectives(pkg *packages.Package, f *ast.File) {
+	keep := func(name *ast.Ident, node ast.Node, directive bool) {
+		obj := pkg.TypesInfo.Defs[name]
+		if obj == nil {
+			return
+		}
+		if !directive && !ucf.isRoot(obj) {
+			return
+		}
+		ucf.keep[ucf.fset.Position(obj.Pos())] = &keptDecl{node: node, info: pkg.TypesInfo}
+	}
+
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			keep(d.Name, d, hasDirective(d.Doc, keepDirective) ||
+				hasDirective(d.Doc, exportDirective) ||
+				hasDirective(d.Doc, wasmexportDirective))
+		case *ast.GenDecl:
+			all := hasDirective(d.Doc, keepDirective)
+			for _, spec := range d.Specs {
+				switch spec := spec.(type) {
+				case *ast.ValueSpec:
+					kept := all || hasDirective(spec.Doc, keepDirective) ||
+						hasDirective(spec.Comment, keepDirective)
+					for _, name := range spec.Names {
+						keep(name, spec, kept)
+					}
+				case *ast.TypeSpec:
+					kept := all || hasDirective(spec.Doc, keepDirective) ||
+						hasDirective(spec.Comment, keepDirective)
+					keep(spec.Name, spec, kept)
+					ast.Inspect(spec.Type, func(n ast.Node) bool {
+						field, ok := n.(*ast.Field)
+						if !ok || !(hasDirective(field.Doc, keepDirective) ||
+							hasDirective(field.Comment, keepDirective)) {
+							return true
+						}
+						for _, name := range field.Names {
+							keep(name, field, true)
+						}
+						if name := embeddedIdent(field); len(field.Names) == 0 && name != nil {
+							keep(name, field, true)
+						}
+						return true
+					})
+				}
+			}
+		}
+	}
+
+	// a linkname can sit anywhere in the file, and names a declaration
+	// of the package that is either provided to or pulled from elsewhere,
+	// along with its counterpart in the other package
+	for _, group := range f.Comments {
+		for _, c := range group.List {
+			fields := strings.Fields(c.Text)
+			if len(fields) < 2 || fields[0] != linknameDirective {
+				continue
+			}
+			if obj := pkg.Types.Scope().Lookup(fields[1]); obj != nil {
+				ucf.keep[ucf.fset.Position(obj.Pos())] = &keptDecl{info: pkg.TypesInfo}
+			}
+			if len(fields) > 2 {
+				ucf.linknames = append(ucf.linknames, fields[2])
+			}
+		}
+	}
+}
+
+// keepLinknames keeps the targets of linkname directives, written as
+// "importpath.name", in whichever analyzed package declares them
+func (ucf *UnusedCodeFinder) keepLinknames() {
+	for _, target := range ucf.linknames {
+		slash := strings.LastIndex(target, "/")
+		dot := strings.Index(target[slash+1:], ".")
+		if dot < 0 {
+			continue
+		}
+		path, name := target[:slash+1+dot], target[slash+2+dot:]
+		for _, pkg := range ucf.targets {
+			if pkg.PkgPath != path || pkg.Types == nil {
+				continue
+			}
+			if obj := linknameObject(pkg.Types, name); obj != nil {
+				ucf.keep[ucf.fset.Position(obj.Pos())] = &keptDecl{info: pkg.TypesInfo}
+			}
+		}
+	}
+}
+
+// linknameObject looks up a linkname's name in a package, which is either
+// a package-level name or a method like "T.m" or "(*T).m"
+func linknameObject(pkg *types.Package, name string) types.Object {
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 {
+		return pkg.Scope().Lookup(name)
+	}
+	recv := strings.Trim(name[:dot], "(*)")
+	tn, ok := pkg.Scope().Lookup(recv).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(tn.Type()), false, pkg, name[dot+1:])
+	if f, ok := obj.(*types.Func); ok {
+		return f
+	}
+	return nil
+}
+
+// isRoot reports whether a package-level object is listed in the roots
+// file, and remembers the entries that were found
+func (ucf *UnusedCodeFinder) isRoot(obj types.Object) bool {
+	if len(ucf.Roots) == 0 || obj.Pkg() == nil {
+		return false
+	}
+	full := obj.Pkg().Path() + "." + obj.Name()
+	if f, ok := obj.(*types.Func); ok {
+		full = f.FullName()
+	}
+	short := strings.ReplaceAll(full, obj.Pkg().Path()+".", obj.Pkg().Name()+".")
+	for _, root := range ucf.Roots {
+		if root == full || root == short {
+			ucf.rootsFound[root] = true
+			return true
+		}
+	}
+	return false
+}
+
+// warnMissingRoots logs every roots file entry that matched nothing
+func (ucf *UnusedCodeFinder) warnMissingRoots() {
+	for _, root := range ucf.Roots {
+		if !ucf.rootsFound[root] {
+			ucf.Errorf("WARNING: root '%v' was not found in the analyzed packages", root)
+		}
+	}
+}
+
+// isKept reports whether the declaration at pos must never be reported
+func (ucf *UnusedCodeFinder) isKept(pos token.Position) bool {
+	_, ok := ucf.keep[pos]
+	return ok
+}
+
+// keptRoots returns the functions kept declarations make reachable: kept
+// functions and methods themselves, plus every function a kept variable,
+// type or field refers to, including literals in its initializer
+func (ucf *UnusedCodeFinder) keptRoots(prog *ssa.Program) []*ssa.Function {
+	if len(ucf.keep) == 0 {
+		return nil
+	}
+	// index the functions of every analyzed package by position; test
+	// variants of a package have their own copy of each
+	funcs := map[token.Position][]*ssa.Function{}
+	literals := []*ssa.Function{}
+	for _, p := range ucf.targets {
+		pkg := prog.Package(p.Types)
+		if pkg == nil || isTestMain(p) {
+			continue
+		}
+		for _, fn := range packageFuncs(prog, pkg) {
+			pos := prog.Fset.Position(fn.Pos())
+			funcs[pos] = append(funcs[pos], fn)
+		}
+		// literals in package-level initializers belong to init
+		literals = append(literals, pkg.Func("init").AnonFuncs...)
+	}
+
+	roots := []*ssa.Function{}
+	for pos, kept := range ucf.keep {
+		if fns, ok := funcs[pos]; ok {
+			roots = append(roots, fns...)
+			continue
+		}
+		if kept.node == nil {
+			continue
+		}
+		ast.Inspect(kept.node, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				if f, ok := kept.info.Uses[id].(*types.Func); ok {
+					roots = append(roots, funcs[ucf.fset.Position(f.Origin().Pos())]...)
+				}
+			}
+			return true
+		})
+		start, end := ucf.fset.Position(kept.node.Pos()), ucf.fset.Position(kept.node.End())
+		for _, lit := range literals {
+			litPos := prog.Fset.Position(lit.Pos())
+			if litPos.Filename == start.Filename &&
+				litPos.Offset >= start.Offset && litPos.Offset < end.Offset {
+				roots = append(roots, lit)
+			}
+		}
+	}
+	return roots
+}
@@ -0,0 +1,265 @@
+package unused
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeptDeclarations(t *testing.T) {
+	Convey("with a test main package and a UnusedCodeFinder", t, func() {
+		ucf := NewUnusedCodeFinder()
+		So(ucf, ShouldNotBeNil)
+
+		Convey("reading the roots file should skip comments", func() {
+			roots, err := ReadRootsFile("./testdata/roots.txt")
+			So(err, ShouldBeNil)
+			So(roots, ShouldResemble, []string{"pkg2.Yowl"})
+		})
+
+		Convey("running 'funcs'", func() {
+			results, err := ucf.Run(testdataPkgs)
+			So(err, ShouldBeNil)
+
+			Convey("kept functions and everything they reach should be live", func() {
+				So("Scratch", ShouldNotBeFoundIn, results)
+				So("groom", ShouldNotBeFoundIn, results)
+			})
+
+			Convey("as well as functions used by kept variables", func() {
+				So("hide", ShouldNotBeFoundIn, results)
+				So("handlers$1", ShouldNotBeFoundIn, results)
+			})
+
+			Convey("but roots file entries only count when given", func() {
+				So("Yowl", ShouldBeFoundIn, results)
+			})
+		})
+
+		Convey("running 'funcs' with a roots file", func() {
+			ucf.Roots = []string{"pkg2.Yowl"}
+			results, err := ucf.Run(testdataPkgs)
+			So(err, ShouldBeNil)
+			So("Yowl", ShouldNotBeFoundIn, results)
+		})
+
+		Convey("running 'idents'", func() {
+			ucf.Idents = true
+			results, err := ucf.Run(testdataPkgs)
+			So(err, ShouldBeNil)
+
+			Convey("kept declarations and what they use should not be found", func() {
+				So("Scratch", ShouldNotBeFoundIn, results)
+				So("groom", ShouldNotBeFoundIn, results)
+				So("handlers", ShouldNotBeFoundIn, results)
+				So("hide", ShouldNotBeFoundIn, results)
+				So("Count", ShouldNotBeFoundIn, results)
+			})
+
+			Convey("but their unkept neighbours should", func() {
+				So("Color", ShouldBeFoundIn, results)
+			})
+		})
+
+		Convey("running 'ssa'", func() {
+			ucf.SSA = true
+			results, err := ucf.Run(testdataPkgs)
+			So(err, ShouldBeNil)
+
+			Convey("kept declarations and what they use should not be found", func() {
+				So("Scratch", ShouldNotBeFoundIn, results)
+				So("groom", ShouldNotBeFoundIn, results)
+				So("handlers", ShouldNotBeFoundIn, results)
+				So("hide", ShouldNotBeFoundIn, results)
+				So("Count", ShouldNotBeFoundIn, results)
+			})
+
+			Convey("but their unkept neighbours should", func() {
+				So("Color", ShouldBeFoundIn, results)
+				So("Whiskers", ShouldBeFoundIn, results)
+			})
+		})
+	})
+}
+
+func TestExternallyCalledFunctions(t *testing.T) {
+	Convey("with functions called from outside of Go and a UnusedCodeFinder", t, func() {
+		ucf := NewUnusedCodeFinder()
+		So(ucf, ShouldNotBeNil)
+
+		Convey("running 'funcs' should treat them as roots", func() {
+			results, err := ucf.Run(testdataPkgs)
+			So(err, ShouldBeNil)
+			So("knead", ShouldNotBeFoundIn, results)
+			So("nap", ShouldNotBeFoundIn, results)
+			So("stretch", ShouldNotBeFoundIn, results)
+			So("shed", ShouldBeFoundIn, results)
+		})
+
+		Convey("running 'idents' should mark them used", func() {
+			ucf.Idents = true
+			results, err := ucf.Run(testdataPkgs)
+			So(err, ShouldBeNil)
+			So("knead", ShouldNotBeFoundIn, results)
+			So("nap", ShouldNotBeFoundIn, results)
+			So("stretch", ShouldNotBeFoundIn, results)
+			So("shed", ShouldBeFoundIn, results)
+		})
+	})
+}
+
+func TestLinknameTargets(t *testing.T) {
+	Convey("with a package whose unexported code other packages link to by name", t, func() {
+		dir, err := ioutil.TempDir("", "linkname")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		So(os.Mkdir(filepath.Join(dir, "impl"), 0755), ShouldBeNil)
+		So(writeFiles(dir, map[string]string{
+			"go.mod": "module example.com/link\n",
+			"main.go": `package main
+
+import (
+	_ "unsafe" // for go:linkname
+
+	"example.com/link/impl"
+)
+
+// pulled is implemented by impl.secret
+//
+//go:linkname pulled example.com/link/impl.secret
+func pulled() int
+
+// pulledMethod is implemented by a method in impl
+//
+//go:linkname pulledMethod example.com/link/impl.(*T).hidden
+func pulledMethod(t *impl.T) int
+
+// received is implemented by impl.push
+func received() int
+
+func main() {
+	pulled()
+	pulledMethod(nil)
+	received()
+}
+`,
+			"impl/impl.go": `package impl
+
+import _ "unsafe" // for go:linkname
+
+// secret is only called through the linkname in main
+func secret() int {
+	return helper()
+}
+
+func helper() int {
+	return 1
+}
+
+type T struct{}
+
+func (t *T) hidden() int {
+	return 2
+}
+
+// push provides main.received
+//
+//go:linkname push example.com/link.received
+func push() int {
+	return 3
+}
+
+func unlinked() int {
+	return 4
+}
+`,
+		}), ShouldBeNil)
+		ucf := NewUnusedCodeFinder()
+		ucf.Dir = dir
+
+		for _, mode := range []string{"funcs", "idents", "ssa"} {
+			mode := mode
+			Convey("running '"+mode+"'", func() {
+				ucf.Idents = mode == "idents"
+				ucf.SSA = mode == "ssa"
+				results, err := ucf.Run([]string{"./..."})
+				So(err, ShouldBeNil)
+
+				Convey("should keep what other packages pull in and what it uses", func() {
+					So("secret", ShouldNotBeFoundIn, results)
+					So("helper", ShouldNotBeFoundIn, results)
+					So("(*T).hidden", ShouldNotBeFoundIn, results)
+				})
+
+				Convey("and what a package pushes out", func() {
+					So("push", ShouldNotBeFoundIn, results)
+				})
+
+				Convey("but not unlinked code", func() {
+					So("unlinked", ShouldBeFoundIn, results)
+				})
+			})
+		}
+	})
+}
+
+func TestCgoPackages(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("no C compiler for cgo")
+	}
+	Convey("with a package that uses cgo and exports a function to C", t, func() {
+		dir, err := ioutil.TempDir("", "cgo")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		So(writeFiles(dir, map[string]string{
+			"go.mod": "module example.com/cgo\n",
+			"main.go": `package main
+
+// static int twice(int x) { return 2 * x; }
+import "C"
+
+import "fmt"
+
+//export knead
+func knead() C.int {
+	return 2
+}
+
+func forgotten() {}
+
+func main() {
+	fmt.Println(C.twice(2))
+}
+`,
+		}), ShouldBeNil)
+		ucf := NewUnusedCodeFinder()
+		ucf.Dir = dir
+
+		for _, mode := range []string{"funcs", "idents", "ssa"} {
+			mode := mode
+			Convey("running '"+mode+"'", func() {
+				ucf.Idents = mode == "idents"
+				ucf.SSA = mode == "ssa"
+				results, err := ucf.Run([]string{"./..."})
+				So(err, ShouldBeNil)
+
+				Convey("should find dead code in the package's own files", func() {
+					So("forgotten", ShouldBeFoundIn, results)
+				})
+
+				Convey("but not functions exported to C", func() {
+					So("knead", ShouldNotBeFoundIn, results)
+				})
+
+				Convey("nor anything in the files cgo generates", func() {
+					for _, o := range results {
+						So(filepath.Base(o.Position.Filename), ShouldEqual, "main.go")
+					}
+				})
+			})
+		}
+	})
+}
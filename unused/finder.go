@@ -5,12 +5,20 @@ package unused
 import (
 	"fmt"
 	"go/ast"
-	"go/parser"
+	"go/build"
 	"go/token"
 	"io"
+	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
 )
 
 type UnusedCodeFinder struct {
@@ -20,23 +28,113 @@ type UnusedCodeFinder struct {
 	Verbose   bool
 	LogWriter io.Writer
 
-	IncludeTests         bool
+	IncludeTests bool
+	BuildTags    []string
+	// Algorithm picks the call graph construction used by 'funcs'
+	// analysis; see Algorithms. Defaults to RTA.
+	Algorithm string
+
+	// Dir is the directory the go tool is run from when loading
+	// packages; it defaults to the current directory
+	Dir string
+	// Workspace loads every module of the governing go.work file
+	// into a single program
+	Workspace bool
+	// Matrix runs the analysis once per build configuration and only
+	// reports code that is dead in all of them
+	Matrix []BuildConfig
+	// SSA replaces the 'funcs' and 'idents' analyses with a single
+	// reachability pass over the SSA callgraph
+	SSA bool
+	// Library treats the exported API of every analyzed package as
+	// used, as callgraph roots or idents roots, for code without a main
+	// package
+	Library bool
+	// Roots lists extra entry points, like "pkg.Func" or
+	// "(*pkg.T).Method"; see ReadRootsFile
+	Roots []string
+	// Since is a git ref; when set, only the dead code in lines changed
+	// since the ref, or made dead by those changes, is reported
+	Since string
+
+	reachableFuncs map[token.Position]bool
+	pkgs           map[string]struct{}
+	funcs          []funcDecl
+	numFilesRead   int
+	env            []string
+
+	// package vars that reachable code reads, by position
+	readGlobals map[token.Position]bool
+
+	// declarations kept by a directive or the roots file
+	keep       map[token.Position]*keptDecl
+	rootsFound map[string]bool
+	// linkname targets in other packages, kept once all are loaded
+	linknames []string
 
-	filesByCaller map[string][]token.Position
-	pkgs          map[string]struct{}
-	funcs         []UnusedObject
-	numFilesRead  int
+	// package data shared by both analysis modes
+	fset    *token.FileSet
+	loaded  []*packages.Package
+	modules []*packages.Module
+	targets []*packages.Package
+	files   map[string]bool
+
+	// every declaration the analysis looked at, dead or alive, and the
+	// line count of each analyzed file by package, for summaries
+	declared  []UnusedObject
+	fileLines map[string]map[string]int
+
+	// the var, const and type declarations of a single spec, by the
+	// position of that spec, since deleting the spec deletes them too
+	loneSpecs map[token.Position]*ast.GenDecl
+
+	// callgraph results
+	callgraph *callgraph.Graph
+	reachable map[*ssa.Function]bool
+	roots     []*ssa.Function
+	mains     []*ssa.Package
 }
 
 func NewUnusedCodeFinder() *UnusedCodeFinder {
-	return &UnusedCodeFinder{
-		// init private storage
-		pkgs:          map[string]struct{}{},
-		filesByCaller: map[string][]token.Position{},
-		funcs:         []UnusedObject{},
+	ucf := &UnusedCodeFinder{
 		// default to stderr; this can be overwritten before Run() is called
 		LogWriter: os.Stderr,
 	}
+	ucf.resetState()
+	return ucf
+}
+
+// resetState (re)initializes the finder's private storage
+func (ucf *UnusedCodeFinder) resetState() {
+	ucf.pkgs = map[string]struct{}{}
+	ucf.reachableFuncs = map[token.Position]bool{}
+	ucf.readGlobals = map[token.Position]bool{}
+	ucf.funcs = []funcDecl{}
+	ucf.numFilesRead = 0
+	ucf.env = nil
+	ucf.fset = nil
+	ucf.loaded = nil
+	ucf.modules = nil
+	ucf.targets = nil
+	ucf.files = map[string]bool{}
+	ucf.declared = nil
+	ucf.fileLines = map[string]map[string]int{}
+	ucf.loneSpecs = map[token.Position]*ast.GenDecl{}
+	ucf.callgraph = nil
+	ucf.reachable = nil
+	ucf.roots = nil
+	ucf.mains = nil
+	ucf.keep = map[token.Position]*keptDecl{}
+	ucf.rootsFound = map[string]bool{}
+	ucf.linknames = nil
+}
+
+// clone returns a finder with the same configuration as this one,
+// but none of its analysis state
+func (ucf *UnusedCodeFinder) clone() *UnusedCodeFinder {
+	c := *ucf
+	c.resetState()
+	return &c
 }
 
 // TODO: move this log stuff to the bottom
@@ -58,6 +156,9 @@ func (ucf *UnusedCodeFinder) Errorf(format string, v ...interface{}) {
 // AddPkg sets the package name as an entry in the package map,
 // here the map holds no values and functions as a hash set
 func (ucf *UnusedCodeFinder) AddPkg(pkgName string) {
+	if _, ok := ucf.pkgs[pkgName]; ok {
+		return
+	}
 	ucf.pkgs[pkgName] = struct{}{}
 	ucf.Logf("Found pkg %v", pkgName)
 }
@@ -70,73 +171,357 @@ func (ucf *UnusedCodeFinder) pkgsAsArray() []string {
 	return packages
 }
 
-func (ucf *UnusedCodeFinder) readFuncsAndImportsFromFile(filename string) error {
-	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, filename, nil, 0)
+// Packages returns the sorted import paths of the analyzed packages
+func (ucf *UnusedCodeFinder) Packages() []string {
+	packages := ucf.pkgsAsArray()
+	sort.Strings(packages)
+	return packages
+}
+
+// loadPackages resolves the given patterns with the go tool, so that
+// "./...", "pkg/..." and module-qualified paths all behave the same way
+// they would for "go build".
+func (ucf *UnusedCodeFinder) loadPackages(patterns []string) error {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles |
+			packages.NeedImports | packages.NeedDeps | packages.NeedTypes |
+			packages.NeedSyntax | packages.NeedTypesInfo | packages.NeedModule,
+		Tests: ucf.IncludeTests,
+		Dir:   ucf.Dir,
+		Env:   ucf.env,
+	}
+	if len(ucf.BuildTags) > 0 {
+		cfg.BuildFlags = []string{"-tags=" + strings.Join(ucf.BuildTags, ",")}
+	}
+	ucf.Logf("Running loader")
+	pkgs, err := packages.Load(cfg, patterns...)
 	if err != nil {
-		return err
+		return fmt.Errorf("error loading program data: %v", err)
+	}
+	if len(pkgs) == 0 {
+		return fmt.Errorf("no packages found matching %v", strings.Join(patterns, " "))
+	}
+	for _, pkg := range pkgs {
+		for _, e := range pkg.Errors {
+			ucf.Errorf("Error loading '%v': %v", pkg.ID, e)
+			ucf.Errorf("Continuing...")
+		}
+		if pkg.Fset != nil {
+			ucf.fset = pkg.Fset
+		}
+	}
+	ucf.loaded = pkgs
+	// remember every module, for printing filenames
+	seen := map[string]bool{}
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if m := pkg.Module; m != nil && m.Dir != "" && !seen[m.Dir] {
+			seen[m.Dir] = true
+			ucf.modules = append(ucf.modules, m)
+		}
+	})
+	return nil
+}
+
+// moduleOf returns the path of the module a package belongs to, for
+// workspace analysis, or the empty string if it isn't part of one
+func (ucf *UnusedCodeFinder) moduleOf(pkg *packages.Package) string {
+	if !ucf.Workspace || pkg.Module == nil {
+		return ""
 	}
+	return pkg.Module.Path
+}
 
-	// check if this is a main packages or
-	// if we want to analyze everything
-	if f.Name.Name == "main" || ucf.Idents || ucf.IncludeTests {
-		pkgName, err := getFullPkgName(filename)
-		if err != nil {
-			return fmt.Errorf("error getting main package path: %v", err)
+// isTestMain reports whether the package is the synthesized main
+// package the go tool generates for running a package's tests
+func isTestMain(pkg *packages.Package) bool {
+	return pkg.Name == "main" && strings.HasSuffix(pkg.ID, ".test")
+}
+
+// collectDeclarations records the functions of every loaded package
+// and picks out which packages should be analyzed
+func (ucf *UnusedCodeFinder) collectDeclarations() {
+	// test variants of a package share its non-test files, so remember
+	// which files were already read (or ignored)
+	ignored := map[string]bool{}
+	for _, pkg := range ucf.loaded {
+		if isTestMain(pkg) {
+			ucf.targets = append(ucf.targets, pkg)
+			continue
+		}
+		analyzed := false
+		for _, f := range ucf.sourceFiles(pkg) {
+			filename := ucf.sourceName(f)
+			skip, seen := ignored[filename]
+			if !seen {
+				skip = ucf.shouldIgnorePath(filename)
+				ignored[filename] = skip
+				if skip {
+					ucf.Logf("Ignoring path '%v'", filename)
+				} else {
+					ucf.readFuncsFromFile(pkg, f)
+					ucf.readDirectives(pkg, f)
+				}
+			}
+			if !skip {
+				analyzed = true
+				ucf.files[filename] = true
+				ucf.addFileLines(pkg.PkgPath, filename, ucf.fileLineCount(f))
+			}
+		}
+		if analyzed {
+			ucf.targets = append(ucf.targets, pkg)
+			ucf.AddPkg(pkg.PkgPath)
 		}
-		ucf.AddPkg(pkgName)
 	}
+	ucf.keepLinknames()
+}
 
-	// iterate over the AST, tracking found functions
-	ast.Inspect(f, func(n ast.Node) bool {
-		var s string
-		switch node := n.(type) {
-		case *ast.FuncDecl:
-			s = node.Name.String()
+// sourceFiles returns the package's files as they were written, leaving
+// out the ones cgo generates into the build cache. The files cgo
+// rewrites are kept, since their line directives map them back to the
+// originals.
+func (ucf *UnusedCodeFinder) sourceFiles(pkg *packages.Package) []*ast.File {
+	goFiles := map[string]bool{}
+	for _, name := range pkg.GoFiles {
+		goFiles[name] = true
+	}
+	files := []*ast.File{}
+	for _, f := range pkg.Syntax {
+		if goFiles[ucf.sourceName(f)] {
+			files = append(files, f)
 		}
-		if s != "" {
+	}
+	return files
+}
+
+// sourceName returns the name of the file a syntax tree was written in,
+// following cgo's line directives
+func (ucf *UnusedCodeFinder) sourceName(f *ast.File) string {
+	return ucf.fset.Position(f.Package).Filename
+}
+
+// fileLineCount returns the number of lines of the file a syntax tree was
+// written in, which for a file cgo rewrote is not the parsed one
+func (ucf *UnusedCodeFinder) fileLineCount(f *ast.File) int {
+	parsed := ucf.fset.File(f.Pos())
+	if name := ucf.sourceName(f); name != parsed.Name() {
+		if data, err := ioutil.ReadFile(name); err == nil {
+			return strings.Count(string(data), "\n")
+		}
+	}
+	return parsed.LineCount()
+}
+
+// addFileLines records the line count of one of a package's files
+func (ucf *UnusedCodeFinder) addFileLines(pkgPath, filename string, lines int) {
+	if ucf.fileLines[pkgPath] == nil {
+		ucf.fileLines[pkgPath] = map[string]int{}
+	}
+	ucf.fileLines[pkgPath][filename] = lines
+}
+
+// funcDecl is a declared function, method or function literal. The
+// test variant of a package has its own copy of every *types.Func, so
+// functions are identified by the position of their name (or of the
+// func keyword, for literals) instead.
+type funcDecl struct {
+	UnusedObject
+	key token.Position
+	// parent is the key of the function enclosing a closure; closures
+	// are only reported when their enclosing function is reachable
+	parent token.Position
+	// global is the position of the package var a literal is assigned
+	// to, which decides whether the literal is live
+	global token.Position
+	// api is set for the exported declarations of non-main packages
+	api bool
+}
+
+func (ucf *UnusedCodeFinder) readFuncsFromFile(pkg *packages.Package, f *ast.File) {
+	// iterate over the top-level declarations, tracking found functions
+	for _, decl := range f.Decls {
+		switch node := decl.(type) {
+		case *ast.FuncDecl:
+			s := node.Name.String()
+			name := s
+			key := ucf.fset.Position(node.Name.Pos())
+			fn, ok := objToFunc(pkg.TypesInfo.Defs[node.Name])
+			if ok {
+				name = handleMethodName(fn)
+			}
 			switch {
 			//TODO make this a helper
 			case strings.Contains(s, "Test"):
 			case s == "main":
 			case s == "init":
 			case s == "test":
+			case !ok:
 			default:
-				ucf.funcs = append(ucf.funcs, UnusedObject{s, fset.Position(n.Pos())})
+				kind := KindFunc
+				if node.Recv != nil {
+					kind = KindMethod
+				}
+				ucf.funcs = append(ucf.funcs, funcDecl{
+					UnusedObject: ucf.newObject(pkg, name, kind, node.Pos(), node),
+					key:          key,
+				})
+			}
+			if node.Body != nil {
+				ucf.readClosures(pkg, node.Body, name, node.Pos(), key)
+			}
+
+		case *ast.GenDecl:
+			if !node.Lparen.IsValid() && len(node.Specs) == 1 {
+				ucf.loneSpecs[ucf.fset.Position(node.Specs[0].Pos())] = node
+			}
+			if node.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range node.Specs {
+				spec := spec.(*ast.ValueSpec)
+				for i, value := range spec.Values {
+					name := "_"
+					if i < len(spec.Names) {
+						name = spec.Names[i].Name
+					}
+					// a literal assigned straight to a package var is named for it
+					if lit, ok := value.(*ast.FuncLit); ok && name != "_" {
+						key := ucf.fset.Position(lit.Pos())
+						ucf.funcs = append(ucf.funcs, funcDecl{
+							UnusedObject: ucf.newObject(pkg, name, KindFunc, spec.Pos(), spec),
+							key:          key,
+							global:       ucf.fset.Position(spec.Names[i].Pos()),
+							api:          token.IsExported(name) && pkg.Name != "main",
+						})
+						ucf.readClosures(pkg, lit.Body, name, spec.Pos(), key)
+						continue
+					}
+					// package initialization always runs, so closures built
+					// there have no enclosing function to check
+					ucf.readClosures(pkg, value, name, spec.Pos(), token.Position{})
+				}
 			}
 		}
-		return true
-	})
+	}
 
 	ucf.numFilesRead++
-	return nil
 }
 
-// helper for directory traversal
-func isDir(filename string) bool {
-	fi, err := os.Stat(filename)
-	return err == nil && fi.IsDir()
+// newObject describes a declaration of pkg reported at pos, whose
+// extent is that of node
+func (ucf *UnusedCodeFinder) newObject(pkg *packages.Package, name, kind string,
+	pos token.Pos, node ast.Node) UnusedObject {
+
+	return UnusedObject{
+		Name:     name,
+		Kind:     kind,
+		Position: ucf.fset.Position(pos),
+		Start:    ucf.fset.Position(ucf.declStart(node)),
+		End:      ucf.fset.Position(node.End()),
+		Package:  pkg.PkgPath,
+		Module:   ucf.moduleOf(pkg),
+		display:  ucf.displayPath(ucf.fset.Position(pos).Filename),
+	}
 }
 
-// helper for grabbing package name from its folder
-func getFullPkgName(filename string) (string, error) {
-	abs, err := filepath.Abs(filename)
-	if err != nil {
-		return "", err
+// declStart returns where deleting the declaration node would start:
+// at its doc comment, and for a spec declared on its own, at the var,
+// const or type keyword (or the doc comment above that)
+func (ucf *UnusedCodeFinder) declStart(node ast.Node) token.Pos {
+	if decl := ucf.loneSpecs[ucf.fset.Position(node.Pos())]; decl != nil {
+		if _, ok := node.(ast.Spec); ok {
+			node = decl
+		}
 	}
-	// strip the GOPATH. Error if this doesn't work.
-	stripped := trimGopath(abs)
-	if stripped != filename {
-		return filepath.Dir(stripped), nil
+	var doc *ast.CommentGroup
+	switch node := node.(type) {
+	case *ast.FuncDecl:
+		doc = node.Doc
+	case *ast.GenDecl:
+		doc = node.Doc
+	case *ast.ValueSpec:
+		doc = node.Doc
+	case *ast.TypeSpec:
+		doc = node.Doc
+	case *ast.Field:
+		doc = node.Doc
 	}
-	// a check during initialization ensures that GOPATH != "" so this should be safe
-	goPaths := filepath.SplitList(os.Getenv("GOPATH"))
-	return "", fmt.Errorf("cd %q and try again", goPaths[len(goPaths)-1])
+	if doc != nil {
+		return doc.Pos()
+	}
+	return node.Pos()
+}
+
+// readClosures tracks the function literals inside node, naming them
+// after their enclosing function the same way the ssa package does
+// (e.g. "outer$1", "outer$1$1") and reporting them at the position of
+// the enclosing declaration, which starts at pos.
+func (ucf *UnusedCodeFinder) readClosures(pkg *packages.Package, node ast.Node,
+	name string, pos token.Pos, parent token.Position) {
+
+	n := 0
+	ast.Inspect(node, func(node ast.Node) bool {
+		lit, ok := node.(*ast.FuncLit)
+		if !ok {
+			return true
+		}
+		n++
+		litName := fmt.Sprintf("%s$%d", name, n)
+		key := ucf.fset.Position(lit.Pos())
+		ucf.funcs = append(ucf.funcs, funcDecl{
+			UnusedObject: ucf.newObject(pkg, litName, KindFunc, pos, lit),
+			key:          key,
+			parent:       parent,
+		})
+		ucf.readClosures(pkg, lit.Body, litName, pos, key)
+		// nested literals were handled by the recursive call
+		return false
+	})
+}
+
+// findModule walks up from dir looking for a go.mod file, returning the
+// module root directory and the module path it declares.
+func findModule(dir string) (root, modPath string, ok bool) {
+	for {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			if modPath = modfile.ModulePath(data); modPath != "" {
+				return dir, modPath, true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", false
+		}
+		dir = parent
+	}
+}
+
+// displayPath shortens an absolute filename for printing, either to its
+// module-qualified path, using the innermost loaded module containing
+// it, or by removing the GOPATH
+func (ucf *UnusedCodeFinder) displayPath(filename string) string {
+	if !filepath.IsAbs(filename) {
+		return filename
+	}
+	var best *packages.Module
+	for _, m := range ucf.modules {
+		if strings.HasPrefix(filename, m.Dir+string(filepath.Separator)) &&
+			(best == nil || len(m.Dir) > len(best.Dir)) {
+			best = m
+		}
+	}
+	if best != nil {
+		if rel, err := filepath.Rel(best.Dir, filename); err == nil {
+			return path.Join(best.Path, filepath.ToSlash(rel))
+		}
+	}
+	return trimGopath(filename)
 }
 
 // trimGopath removes the GOPATH from a filepath, for simplicity
 func trimGopath(filename string) string {
-	goPaths := filepath.SplitList(os.Getenv("GOPATH"))
+	goPaths := filepath.SplitList(build.Default.GOPATH)
 	for _, p := range goPaths {
 		p = filepath.Join(p, "src") + string(filepath.Separator)
 		if !strings.HasPrefix(filename, p) {
@@ -148,17 +533,6 @@ func trimGopath(filename string) string {
 	return filename
 }
 
-func (ucf *UnusedCodeFinder) canReadSourceFile(filename string) bool {
-	if ucf.shouldIgnorePath(filename) {
-		ucf.Logf("Ignoring path '%v'", filename)
-		return false
-	}
-	if !strings.HasSuffix(filename, ".go") {
-		return false
-	}
-	return true
-}
-
 func (ucf *UnusedCodeFinder) shouldIgnorePath(path string) bool {
 	for _, ignoreToken := range ucf.Ignore {
 		if strings.Contains(path, ignoreToken) {
@@ -172,54 +546,67 @@ func (ucf *UnusedCodeFinder) shouldIgnorePath(path string) bool {
 	return false
 }
 
-func (ucf *UnusedCodeFinder) readDir(dirname string) error {
-	err := filepath.Walk(dirname, func(path string, info os.FileInfo, err error) error {
-		if err == nil && !info.IsDir() && ucf.canReadSourceFile(path) {
-			err = ucf.readFuncsAndImportsFromFile(path)
-		}
-		return err
-	})
-	return err
+func (ucf *UnusedCodeFinder) Run(patterns []string) ([]UnusedObject, error) {
+	unusedObjects, err := ucf.run(patterns)
+	if err != nil || ucf.Since == "" {
+		return unusedObjects, err
+	}
+	return ucf.changedSince(unusedObjects, patterns)
 }
 
-func (ucf *UnusedCodeFinder) Run(fileArgs []string) ([]UnusedObject, error) {
-
-	// do some basic sanity checks on system configuration
-	if len(fileArgs) == 0 {
-		return nil, fmt.Errorf(
-			"no files supplied as arguments; must supply at least one file or directory")
-	}
-	if os.Getenv("GOPATH") == "" {
-		return nil, fmt.Errorf("GOPATH not set")
+func (ucf *UnusedCodeFinder) run(patterns []string) ([]UnusedObject, error) {
+	if len(ucf.Matrix) > 0 {
+		return ucf.runMatrix(patterns)
 	}
 
-	// first, get all the file names and package imports
-	ucf.Logf("Collecting declarations from source files")
-	for _, filename := range fileArgs {
-		if strings.HasSuffix(filename, "/...") && isDir(filename[:len(filename)-4]) {
-			// go tool ./... style
-			if err := ucf.readDir(filename[:len(filename)-4]); err != nil {
-				ucf.Errorf("Error reading '...': %v", err.Error())
-				ucf.Errorf("Continuing...")
-			}
-		} else if isDir(filename) {
-			if err := ucf.readDir(filename); err != nil {
-				ucf.Errorf("Error reading '%v' directory: %v", filename, err.Error())
-				ucf.Errorf("Continuing...")
-			}
-		} else {
-			if ucf.canReadSourceFile(filename) {
-				if err := ucf.readFuncsAndImportsFromFile(filename); err != nil {
-					ucf.Errorf("Error reading '%v' file: %v", filename, err.Error())
-					ucf.Errorf("Continuing...")
-				}
-			}
-		}
+	if err := ucf.load(patterns); err != nil {
+		return nil, err
 	}
-	ucf.Logf("Parsed %v source files", ucf.numFilesRead)
 
+	if ucf.SSA {
+		return ucf.findUnusedWithSSA()
+	}
 	if ucf.Idents {
 		return ucf.findUnusedIdents()
 	}
 	return ucf.findUnusedFuncs()
 }
+
+// Mode names the analysis Run performs: "funcs", "idents" or "ssa"
+func (ucf *UnusedCodeFinder) Mode() string {
+	switch {
+	case ucf.SSA:
+		return "ssa"
+	case ucf.Idents:
+		return "idents"
+	}
+	return "funcs"
+}
+
+// load loads the packages matching the patterns, plus the modules of the
+// workspace in workspace mode, and collects their declarations
+func (ucf *UnusedCodeFinder) load(patterns []string) error {
+	if ucf.Workspace {
+		modPatterns, err := ucf.workspacePatterns()
+		if err != nil {
+			return fmt.Errorf("error reading workspace: %v", err)
+		}
+		patterns = append(modPatterns, patterns...)
+	}
+
+	// do some basic sanity checks on system configuration
+	if len(patterns) == 0 {
+		return fmt.Errorf(
+			"no packages supplied as arguments; must supply at least one package pattern")
+	}
+
+	// first, load the packages and collect their declarations
+	ucf.Logf("Collecting declarations from source files")
+	if err := ucf.loadPackages(patterns); err != nil {
+		return err
+	}
+	ucf.collectDeclarations()
+	ucf.warnMissingRoots()
+	ucf.Logf("Parsed %v source files", ucf.numFilesRead)
+	return nil
+}
@@ -2,10 +2,10 @@ package unused
 
 import (
 	"fmt"
+	"go/token"
+	"go/types"
 	"strings"
 
-	"golang.org/x/tools/go/callgraph/rta"
-	"golang.org/x/tools/go/loader"
 	"golang.org/x/tools/go/ssa"
 	"golang.org/x/tools/go/ssa/ssautil"
 )
@@ -26,96 +26,233 @@ func (ucf *UnusedCodeFinder) findUnusedFuncs() ([]UnusedObject, error) {
 	return unusedFuncs, nil
 }
 
-func (ucf *UnusedCodeFinder) getCallgraph() error {
-	var conf loader.Config
-	_, err := conf.FromArgs(ucf.pkgsAsArray(), ucf.IncludeTests)
+// buildProgram builds the SSA form of the loaded packages and finds
+// their main packages
+func (ucf *UnusedCodeFinder) buildProgram() (*ssa.Program, []*ssa.Package, error) {
+	// the callgraph packages can't handle uninstantiated generic code
+	buildMode := ssa.InstantiateGenerics
+	if ucf.Verbose {
+		buildMode |= ssa.GlobalDebug
+	}
+	ssaP, _ := ssautil.AllPackages(ucf.loaded, buildMode)
+	ssaP.Build()
+	mains, err := ucf.getMains(ssaP)
 	if err != nil {
-		return fmt.Errorf("error loading program data: %v", err)
+		return nil, nil, fmt.Errorf("error finding roots for callgraph analysis: %v", err)
 	}
-	conf.AllowErrors = true
-	ucf.Logf("Running loader")
-	p, err := conf.Load()
+	return ssaP, mains, nil
+}
+
+func (ucf *UnusedCodeFinder) getCallgraph() error {
+	ssaP, mains, err := ucf.buildProgram()
 	if err != nil {
-		return fmt.Errorf("error loading program data: %v", err)
+		return err
 	}
-	var buildMode ssa.BuilderMode
-	if ucf.Verbose {
-		buildMode = ssa.GlobalDebug
+	roots := ucf.getRoots(ssaP, mains)
+	if len(roots) == 0 {
+		return fmt.Errorf("error finding roots for callgraph analysis: " +
+			"no main packages, exported functions or kept functions found")
 	}
-	ssaP := ssautil.CreateProgram(p, buildMode)
-	ssaP.Build()
-	roots, err := ucf.getRoots(ssaP)
+	ucf.Logf("Building callgraph with %v", ucf.algorithm())
+	cg, reachable, err := ucf.analyze(ssaP, roots)
 	if err != nil {
-		return fmt.Errorf("error finding roots for callgraph analysis: %v", err)
+		return err
 	}
-	res := rta.Analyze(roots, true)
+	ucf.callgraph = cg
+	ucf.reachable = reachable
+	ucf.roots = roots
+	ucf.mains = mains
 
-	// build a simplified callgraph map for name->filenames
-	for node, _ := range res.Reachable {
-		position := ssaP.Fset.Position(node.Pos())
-		ucf.filesByCaller[node.Name()] = append(ucf.filesByCaller[node.Name()], position)
+	// record the declared function or literal behind every reachable node
+	for node := range reachable {
+		if key, ok := ucf.funcKey(node); ok {
+			ucf.reachableFuncs[key] = true
+		}
 	}
+
+	// a literal assigned to a package var is live if reachable code loads
+	// the var, whatever the algorithm thinks of the literal itself; RTA
+	// reaches every address-taken function once reflection can call it
+	ucf.readGlobals = ucf.loadedGlobals(reachable)
 	return nil
 }
 
-func (ucf *UnusedCodeFinder) isInCG(f UnusedObject) bool {
-	for _, pos := range ucf.filesByCaller[f.Name] {
-		if strings.Contains(pos.Filename, f.Position.Filename) {
-			return true
+// loadedGlobals returns the positions of the package vars that the
+// reachable functions read or take the address of, as opposed to only
+// storing to them, like a package initializer does
+func (ucf *UnusedCodeFinder) loadedGlobals(reachable map[*ssa.Function]bool) map[token.Position]bool {
+	loaded := map[token.Position]bool{}
+	for fn := range reachable {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				for _, op := range instr.Operands(nil) {
+					g, ok := (*op).(*ssa.Global)
+					if !ok {
+						continue
+					}
+					if store, ok := instr.(*ssa.Store); ok && store.Addr == g {
+						continue
+					}
+					loaded[ucf.fset.Position(g.Pos())] = true
+				}
+			}
 		}
 	}
-	return false
+	return loaded
+}
+
+// funcKey returns the position a callgraph node's function is declared
+// at, which is the key of its funcDecl
+func (ucf *UnusedCodeFinder) funcKey(node *ssa.Function) (token.Position, bool) {
+	if fn := declaredFunc(node); fn != nil {
+		return ucf.fset.Position(fn.Pos()), true
+	}
+	if node.Parent() != nil {
+		// anonymous functions are positioned at their func keyword
+		return ucf.fset.Position(node.Pos()), true
+	}
+	return token.Position{}, false
+}
+
+// declaredFunc returns the source-level function or method an ssa
+// function was built from, if any. Generic instances map back to
+// their origin, and wrappers to the method they wrap.
+func declaredFunc(node *ssa.Function) *types.Func {
+	if node.Origin() != nil {
+		node = node.Origin()
+	}
+	fn, _ := node.Object().(*types.Func)
+	return fn
+}
+
+func (ucf *UnusedCodeFinder) isInCG(f funcDecl) bool {
+	if f.global.IsValid() {
+		// a func-valued var is live when live code reads it, or when it
+		// is part of a library's exported API
+		return ucf.readGlobals[f.global] || ucf.Library && f.api
+	}
+	return ucf.reachableFuncs[f.key]
 }
 
 func (ucf *UnusedCodeFinder) computeUnusedFuncs() []UnusedObject {
 	unused := []UnusedObject{}
+	dead := map[token.Position]bool{}
 	for _, f := range ucf.funcs {
+		ucf.declared = append(ucf.declared, f.UnusedObject)
+		// closures inside dead code are dead too, but deleting the
+		// enclosing function already takes care of them
+		if f.parent.IsValid() && (!ucf.reachableFuncs[f.parent] || dead[f.parent]) {
+			continue
+		}
+		if ucf.isKept(f.key) || f.global.IsValid() && ucf.isKept(f.global) {
+			continue
+		}
 		if !ucf.isInCG(f) {
-			unused = append(unused, f)
+			dead[f.key] = true
+			unused = append(unused, f.UnusedObject)
 		}
 	}
 	return unused
 }
 
-// grab the callgraph roots from the passed in files. This is based on adonovan's
+// algorithm returns the name of the call graph algorithm in use
+func (ucf *UnusedCodeFinder) algorithm() string {
+	if ucf.Algorithm == "" {
+		return AlgorithmRTA
+	}
+	return ucf.Algorithm
+}
+
+// grab the main packages from the passed in files. This is based on adonovan's
 // code from https://github.com/golang/tools/blob/master/cmd/callgraph/main.go
-func (ucf *UnusedCodeFinder) getRoots(prog *ssa.Program) ([]*ssa.Function, error) {
-	pkgs := prog.AllPackages()
+func (ucf *UnusedCodeFinder) getMains(prog *ssa.Program) ([]*ssa.Package, error) {
 	mains := []*ssa.Package{}
 
-	// create a test main if the user requests it
-	if ucf.IncludeTests {
-		if len(pkgs) > 0 {
-			ucf.Logf("Building a test main for analysis")
-			for _, pkg := range pkgs {
-				if main := prog.CreateTestMainPackage(pkg); main != nil {
-					mains = append(mains, main)
-				} else {
-					ucf.Logf("WARNING: -tests flag specified, but no test files found for %s", pkg)
-				}
-			}
-		} else {
-			return nil, fmt.Errorf("no packages specified")
+	// find *all* main packages, including the test mains the
+	// loader generates if the user requests tests
+	testMains := 0
+	for _, p := range ucf.targets {
+		pkg := prog.Package(p.Types)
+		if pkg == nil || pkg.Pkg.Name() != "main" {
+			continue
 		}
-	}
-
-	// then find *all* main packages
-	for _, pkg := range pkgs {
-		if pkg.Pkg.Name() == "main" {
-			if pkg.Func("main") == nil {
-				return nil, fmt.Errorf("no func main() in main package")
-			}
-			mains = append(mains, pkg)
+		if pkg.Func("main") == nil {
+			return nil, fmt.Errorf("no func main() in main package")
 		}
+		if isTestMain(p) {
+			testMains++
+		}
+		mains = append(mains, pkg)
+	}
+	if ucf.IncludeTests && testMains == 0 {
+		ucf.Logf("WARNING: -tests flag specified, but no test files found")
 	}
-	if len(mains) == 0 {
+	if len(mains) == 0 && !ucf.Library && len(ucf.keep) == 0 {
 		return nil, fmt.Errorf("no main packages found")
 	}
+	return mains, nil
+}
 
+// getRoots returns the callgraph roots of the main packages, plus the
+// exported API of every other package in library mode and anything kept
+// by a directive or the roots file
+func (ucf *UnusedCodeFinder) getRoots(prog *ssa.Program, mains []*ssa.Package) []*ssa.Function {
 	roots := []*ssa.Function{}
 	for _, root := range mains {
 		roots = append(roots, root.Func("init"), root.Func("main"))
 	}
+	if ucf.Library {
+		// methods of unexported types count as well, since the types
+		// can still be handed out by exported functions
+		for _, p := range ucf.targets {
+			if pkg := prog.Package(p.Types); pkg != nil && pkg.Pkg.Name() != "main" {
+				for _, fn := range packageFuncs(prog, pkg) {
+					if fn.Name() == "init" || token.IsExported(fn.Name()) {
+						roots = append(roots, fn)
+					}
+				}
+			}
+		}
+	}
+	return append(roots, ucf.keptRoots(prog)...)
+}
 
-	return roots, nil
+// packageFuncs returns every package-level function of a package,
+// including init, along with the methods of its named types. Generic
+// functions and methods are returned uninstantiated, since their bodies
+// still reach everything any instance of them could.
+func packageFuncs(prog *ssa.Program, pkg *ssa.Package) []*ssa.Function {
+	funcs := []*ssa.Function{}
+	for _, member := range pkg.Members {
+		switch member := member.(type) {
+		case *ssa.Function:
+			funcs = append(funcs, member)
+		case *ssa.Type:
+			named, ok := member.Type().(*types.Named)
+			if !ok {
+				continue
+			}
+			if named.TypeParams().Len() > 0 {
+				// a generic type has no method sets until instantiated
+				for i := 0; i < named.NumMethods(); i++ {
+					if fn := prog.FuncValue(named.Method(i)); fn != nil {
+						funcs = append(funcs, fn)
+					}
+				}
+				continue
+			}
+			for _, t := range []types.Type{named, types.NewPointer(named)} {
+				mset := prog.MethodSets.MethodSet(t)
+				for i := 0; i < mset.Len(); i++ {
+					sel := mset.At(i)
+					if sel.Obj().Pkg() == pkg.Pkg {
+						if fn := prog.MethodValue(sel); fn != nil {
+							funcs = append(funcs, fn)
+						}
+					}
+				}
+			}
+		}
+	}
+	return funcs
 }
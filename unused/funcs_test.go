@@ -3,7 +3,9 @@ package unused
 import (
 	"fmt"
 	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -15,6 +17,10 @@ func init() {
 	}
 }
 
+// the go tool never matches testdata directories with "...", so the
+// test packages have to be listed one by one
+var testdataPkgs = []string{"./testdata", "./testdata/pkg1", "./testdata/pkg2"}
+
 // helpers for convey
 func ShouldBeFoundIn(actual interface{}, expected ...interface{}) string {
 	// this can panic, but I'm not adding type checking
@@ -46,7 +52,7 @@ func TestUnusedFuncsWithMain(t *testing.T) {
 		So(ucf, ShouldNotBeNil)
 
 		Convey("running 'funcs'", func() {
-			results, err := ucf.Run([]string{"testdata"})
+			results, err := ucf.Run(testdataPkgs)
 			So(err, ShouldBeNil)
 
 			Convey("all functions in pkg1 and pkg2 that main does not use should be found", func() {
@@ -60,6 +66,31 @@ func TestUnusedFuncsWithMain(t *testing.T) {
 				So("ColorKittenLink", ShouldNotBeFoundIn, results)
 				So("init", ShouldNotBeFoundIn, results)
 			})
+
+			Convey("methods should be told apart by their receiver type", func() {
+				So("(unusedType).Val", ShouldBeFoundIn, results)
+				So("(calico).Meow", ShouldBeFoundIn, results)
+				So("(Tabby).Meow", ShouldNotBeFoundIn, results)
+				for _, o := range results {
+					switch o.Name {
+					case "(calico).Meow":
+						So(o.Kind, ShouldEqual, KindMethod)
+					case "GrayKittenLink", "Pounce$2":
+						So(o.Kind, ShouldEqual, KindFunc)
+					}
+				}
+			})
+
+			Convey("closures that are never called should be found", func() {
+				So("Pounce$2", ShouldBeFoundIn, results)
+				So("Pounce$1", ShouldNotBeFoundIn, results)
+				So("Pounce", ShouldNotBeFoundIn, results)
+			})
+
+			Convey("func-valued vars that are never loaded should be found", func() {
+				So("hiss", ShouldBeFoundIn, results)
+				So("Purr", ShouldNotBeFoundIn, results)
+			})
 		})
 	})
 }
@@ -71,7 +102,7 @@ func TestUnusedFuncsWithTests(t *testing.T) {
 		ucf.IncludeTests = true
 
 		Convey("running 'funcs'", func() {
-			results, err := ucf.Run([]string{"testdata"})
+			results, err := ucf.Run(testdataPkgs)
 			So(err, ShouldBeNil)
 
 			Convey("all functions that are unused by any pkg or test are found", func() {
@@ -88,3 +119,273 @@ func TestUnusedFuncsWithTests(t *testing.T) {
 		})
 	})
 }
+
+func TestUnusedFuncsWithOtherAlgorithms(t *testing.T) {
+	for _, algo := range []string{AlgorithmCHA, AlgorithmVTA} {
+		Convey("with a test main package and a UnusedCodeFinder using "+algo, t, func() {
+			ucf := NewUnusedCodeFinder()
+			So(ucf, ShouldNotBeNil)
+			ucf.Algorithm = algo
+
+			Convey("running 'funcs'", func() {
+				results, err := ucf.Run(testdataPkgs)
+				So(err, ShouldBeNil)
+
+				Convey("the same unused functions should be found", func() {
+					So("oldHelper", ShouldBeFoundIn, results)
+					So("GenSix", ShouldBeFoundIn, results)
+					So("GenUInt", ShouldBeFoundIn, results)
+					So("toUint", ShouldBeFoundIn, results)
+					So("GrayKittenLink", ShouldBeFoundIn, results)
+					So("GenInt", ShouldNotBeFoundIn, results)
+					So("GenIntMod400", ShouldNotBeFoundIn, results)
+					So("ColorKittenLink", ShouldNotBeFoundIn, results)
+				})
+
+				Convey("along with func-valued vars that are never loaded", func() {
+					So("hiss", ShouldBeFoundIn, results)
+					So("Purr", ShouldNotBeFoundIn, results)
+				})
+			})
+		})
+	}
+
+	Convey("with an unknown algorithm", t, func() {
+		ucf := NewUnusedCodeFinder()
+		ucf.Algorithm = "magic"
+		_, err := ucf.Run(testdataPkgs)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("with the pointer algorithm, which x/tools no longer has", t, func() {
+		ucf := NewUnusedCodeFinder()
+		ucf.Algorithm = "pointer"
+		_, err := ucf.Run(testdataPkgs)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "go/pointer was removed")
+	})
+}
+
+func TestUnusedFuncsInLibraryMode(t *testing.T) {
+	Convey("with a package that has no main and a UnusedCodeFinder in library mode", t, func() {
+		ucf := NewUnusedCodeFinder()
+		So(ucf, ShouldNotBeNil)
+		ucf.Library = true
+
+		Convey("running 'funcs'", func() {
+			results, err := ucf.Run([]string{"./testdata/pkg1"})
+			So(err, ShouldBeNil)
+
+			Convey("the exported API and everything it reaches should be live", func() {
+				So("GenInt", ShouldNotBeFoundIn, results)
+				So("GenUInt", ShouldNotBeFoundIn, results)
+				So("GenSix", ShouldNotBeFoundIn, results)
+				So("toUint", ShouldNotBeFoundIn, results)
+			})
+
+			Convey("but unexported helpers no entry point reaches should be found", func() {
+				So("isEven", ShouldBeFoundIn, results)
+				So("isOdd", ShouldBeFoundIn, results)
+			})
+		})
+	})
+
+	Convey("without library mode, the same package has no roots", t, func() {
+		ucf := NewUnusedCodeFinder()
+		_, err := ucf.Run([]string{"./testdata/pkg1"})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+// genericLibrary is a library whose exported API is generic
+var genericLibrary = map[string]string{
+	"go.mod": "module example.com/generic\n\ngo 1.21\n",
+	"generic.go": `package generic
+
+func Map[T any](xs []T, f func(T) T) []T {
+	for i := range xs {
+		xs[i] = f(xs[i])
+	}
+	return apply(xs)
+}
+
+func apply[T any](xs []T) []T {
+	mapHelper()
+	return xs
+}
+
+func mapHelper() {}
+
+type Stack[T any] struct{ items []T }
+
+func (s *Stack[T]) Push(x T) {
+	s.items = append(s.items, x)
+	pushHelper()
+}
+
+func pushHelper() {}
+
+func unreached() {}
+`,
+}
+
+func TestUnusedFuncsInGenericLibrary(t *testing.T) {
+	Convey("with a generic library and a UnusedCodeFinder in library mode", t, func() {
+		dir, err := ioutil.TempDir("", "generic")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		So(writeFiles(dir, genericLibrary), ShouldBeNil)
+		ucf := NewUnusedCodeFinder()
+		ucf.Dir = dir
+		ucf.Library = true
+
+		for _, algo := range Algorithms {
+			algo := algo
+			Convey("running 'funcs' with "+algo, func() {
+				ucf.Algorithm = algo
+				results, err := ucf.Run([]string{"./..."})
+				So(err, ShouldBeNil)
+
+				Convey("exported generic functions and what they call should be live", func() {
+					So("Map", ShouldNotBeFoundIn, results)
+					So("apply", ShouldNotBeFoundIn, results)
+					So("mapHelper", ShouldNotBeFoundIn, results)
+				})
+
+				Convey("and so should methods of generic types", func() {
+					So("(Stack[T]).Push", ShouldNotBeFoundIn, results)
+					So("pushHelper", ShouldNotBeFoundIn, results)
+				})
+
+				Convey("but not what nothing calls", func() {
+					So("unreached", ShouldBeFoundIn, results)
+				})
+			})
+		}
+
+		Convey("running 'ssa' should keep the fields the generic methods use", func() {
+			ucf.SSA = true
+			results, err := ucf.Run([]string{"./..."})
+			So(err, ShouldBeNil)
+			So("items", ShouldNotBeFoundIn, results)
+			So("pushHelper", ShouldNotBeFoundIn, results)
+			So("unreached", ShouldBeFoundIn, results)
+		})
+	})
+}
+
+func TestUnusedFuncValuedVars(t *testing.T) {
+	Convey("with func-valued vars that are never read and a UnusedCodeFinder", t, func() {
+		dir, err := ioutil.TempDir("", "funcvars")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		So(os.Mkdir(filepath.Join(dir, "lib"), 0755), ShouldBeNil)
+		So(writeFiles(dir, map[string]string{
+			"go.mod": "module example.com/funcvars\n",
+			"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/funcvars/lib"
+)
+
+// hook is looked up through reflection
+//
+//codecoroner:keep
+var hook = func() {}
+
+// listed is named in the roots file
+var listed = func() {}
+
+// forgotten is never read
+var forgotten = func() {}
+
+func main() {
+	fmt.Println(lib.Used())
+}
+`,
+			"lib/lib.go": `package lib
+
+// Exported is never read here, but it's part of the API
+var Exported = func() string {
+	return "exported"
+}
+
+var hidden = func() string {
+	return "hidden"
+}
+
+func Used() string {
+	return "used"
+}
+`,
+		}), ShouldBeNil)
+		ucf := NewUnusedCodeFinder()
+		ucf.Dir = dir
+		ucf.Roots = []string{"main.listed"}
+
+		Convey("running 'funcs' should keep kept and listed vars", func() {
+			results, err := ucf.Run([]string{"./..."})
+			So(err, ShouldBeNil)
+			So("hook", ShouldNotBeFoundIn, results)
+			So("listed", ShouldNotBeFoundIn, results)
+			So("forgotten", ShouldBeFoundIn, results)
+
+			Convey("but not exported vars outside of library mode", func() {
+				So("Exported", ShouldBeFoundIn, results)
+			})
+		})
+
+		Convey("running 'funcs' in library mode should keep exported vars", func() {
+			ucf.Library = true
+			results, err := ucf.Run([]string{"./..."})
+			So(err, ShouldBeNil)
+			So("Exported", ShouldNotBeFoundIn, results)
+			So("hidden", ShouldBeFoundIn, results)
+			So("hook", ShouldNotBeFoundIn, results)
+		})
+	})
+
+	Convey("with a UnusedCodeFinder in library mode", t, func() {
+		ucf := NewUnusedCodeFinder()
+		ucf.Library = true
+
+		Convey("running 'funcs' on pkg2 should keep its exported func-valued var", func() {
+			results, err := ucf.Run([]string{"./testdata/pkg2"})
+			So(err, ShouldBeNil)
+			So("Purr", ShouldNotBeFoundIn, results)
+			So("hiss", ShouldBeFoundIn, results)
+		})
+	})
+}
+
+func TestUnusedFuncsInModuleOutsideGopath(t *testing.T) {
+	Convey("with a module in a temporary directory outside of GOPATH", t, func() {
+		dir, err := ioutil.TempDir("", "outside")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		So(writeFiles(dir, map[string]string{
+			"go.mod": "module example.com/outside\n",
+			"main.go": `package main
+
+func main() {}
+
+func forgotten() {}
+`,
+		}), ShouldBeNil)
+		ucf := NewUnusedCodeFinder()
+		ucf.Dir = dir
+
+		Convey("running 'funcs' should find its dead code", func() {
+			results, err := ucf.Run([]string{"./..."})
+			So(err, ShouldBeNil)
+			So("forgotten", ShouldBeFoundIn, results)
+
+			Convey("printed with its module-qualified path", func() {
+				So(results[0].File(), ShouldEqual, "example.com/outside/main.go")
+				So(results[0].String(), ShouldEqual, "example.com/outside/main.go:5:1: forgotten (1 line)")
+			})
+		})
+	})
+}
@@ -2,10 +2,10 @@ package unused
 
 import (
 	"fmt"
+	"go/ast"
 	"go/token"
+	"go/types"
 	"strings"
-
-	"golang.org/x/tools/go/loader"
 )
 
 // shorten the method name for nicer printing and say if its a method
@@ -26,75 +26,301 @@ func handleMethodName(f typeFunc) string {
 
 type ident struct {
 	Name string
-	Pos  token.Pos
+	Pos  token.Position
 }
 
-func (ucf *UnusedCodeFinder) findUnusedIdents() ([]UnusedObject, error) {
-	var conf loader.Config
-	_, err := conf.FromArgs(ucf.pkgsAsArray(), ucf.IncludeTests)
-	if err != nil {
-		return nil, fmt.Errorf("error loading program data: %v", err)
-	}
-	conf.AllowErrors = true
-	ucf.Logf("Running loader")
-	p, err := conf.Load()
-	if err != nil {
-		return nil, fmt.Errorf("error loading program data: %v", err)
+// identFor names a declared object the way it's printed in results
+func (ucf *UnusedCodeFinder) identFor(obj types.Object) ident {
+	name := obj.Name()
+	if f, ok := objToFunc(obj); ok {
+		//special case for methods
+		name = handleMethodName(f)
 	}
+	return ident{Name: name, Pos: ucf.fset.Position(obj.Pos())}
+}
 
-	identToUsage := map[ident]int{}
-	defined := map[ident]struct{}{}
+// identDecl is a top-level declaration, along with the package-level
+// identifiers it declares and every identifier used inside it
+type identDecl struct {
+	topDefs []ident
+	uses    []ident
+	// converted are the types of the values converted to interfaces
+	converted []types.Type
+	// root declarations are never reported, so they are always live
+	root bool
+}
 
-	for key, info := range p.Imported {
-		if strings.Contains(key, ".") { //TODO do we need this if?
+func (ucf *UnusedCodeFinder) findUnusedIdents() ([]UnusedObject, error) {
+	defined := map[ident]UnusedObject{}
+	owner := map[ident]token.Position{} // ident -> enclosing declaration
+	topDecl := map[ident]token.Position{}
+	decls := map[token.Position]*identDecl{}
+	exported := map[ident]bool{} // the API of libraries in library mode
 
-			// find all *used* idents
-			for _, kind := range info.Info.Uses {
-				if kind.Pkg() != nil {
-					name := kind.Name()
-					if f, ok := objToFunc(kind); ok {
-						//special case for methods
-						name = handleMethodName(f)
-					}
-					id := ident{Name: name, Pos: kind.Pos()}
-					identToUsage[id] = identToUsage[id] + 1
-				}
+	for _, pkg := range ucf.targets {
+		if isTestMain(pkg) || pkg.TypesInfo == nil {
+			continue
+		}
+		info := pkg.TypesInfo
+		// in library mode, the exported API of non-main packages is used
+		// by definition, the same as in 'funcs' and 'ssa'
+		lib := ucf.Library && pkg.Name != "main"
+		for _, f := range ucf.sourceFiles(pkg) {
+			if ucf.shouldIgnorePath(ucf.sourceName(f)) {
+				continue
 			}
-
-			// find all *declared* idents
-			for _, kind := range info.Info.Defs {
-				if kind == nil {
+			for _, d := range f.Decls {
+				if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+					continue
+				}
+				// test variants share the non-test files, so only read each once
+				key := ucf.fset.Position(d.Pos())
+				if _, seen := decls[key]; seen {
 					continue
 				}
-				if kind.Pkg() != nil {
-					name := kind.Name()
-					if name == "_" ||
-						name == "main" ||
-						name == "init" ||
-						strings.HasPrefix(name, "Test") {
-						continue
+				decl := &identDecl{}
+				decls[key] = decl
+				topLevel := topLevelNames(d)
+				params := paramNames(d)
+				fields := fieldNodes(d)
+				funcName := ""
+				if fd, ok := d.(*ast.FuncDecl); ok && info.Defs[fd.Name] != nil {
+					funcName = ucf.identFor(info.Defs[fd.Name]).Name
+				}
+				scopes := scopeNames(d, funcName)
+
+				ast.Inspect(d, func(n ast.Node) bool {
+					id, ok := n.(*ast.Ident)
+					if !ok {
+						return true
 					}
-					if f, ok := objToFunc(kind); ok {
-						name = handleMethodName(f)
+					// find all *declared* idents
+					if kind := info.Defs[id]; kind != nil && kind.Pkg() != nil {
+						if skipDeclName(kind.Name()) {
+							if topLevel[id] != nil {
+								decl.root = true
+							}
+						} else if def := ucf.identFor(kind); def.Name != "." {
+							// package-level names and fields end with their declaration
+							var end ast.Node = id
+							if node := topLevel[id]; node != nil {
+								end = node
+							} else if field := fields[id]; field != nil {
+								end = field
+							}
+							objKind := kindOf(kind)
+							if params[id] {
+								objKind = KindParam
+							}
+							obj := ucf.newObject(pkg, def.Name, objKind, id.Pos(), end)
+							if topLevel[id] == nil {
+								obj.scope = scopes[id]
+							}
+							defined[def] = obj
+							owner[def] = key
+							if topLevel[id] != nil {
+								decl.topDefs = append(decl.topDefs, def)
+								topDecl[def] = key
+								if ucf.isKept(def.Pos) {
+									decl.root = true
+								}
+							}
+							// exported methods count even on unexported types,
+							// which exported functions can still hand out
+							if lib && kind.Exported() && (topLevel[id] != nil || isExportedDecl(kind)) {
+								exported[def] = true
+								if topLevel[id] != nil {
+									decl.root = true
+								}
+							}
+						}
 					}
-					if name == "." {
-						continue
+					// find all *used* idents
+					if kind := info.Uses[id]; kind != nil && kind.Pkg() != nil {
+						decl.uses = append(decl.uses, ucf.identFor(kind))
 					}
-					id := ident{Name: name, Pos: kind.Pos()}
-					defined[id] = struct{}{}
-				}
+					return true
+				})
+				decl.converted = convertedTypes(info, d)
 			}
 		}
 	}
+
+	// converting a value to an interface uses the methods the interface
+	// can call dynamically, like String for a value printed with fmt
+	ifaces := interfaceTypes(ucf.targets)
+	methods := map[string][]ident{}
+	for _, decl := range decls {
+		for _, t := range decl.converted {
+			key := types.TypeString(t, nil)
+			if _, ok := methods[key]; !ok {
+				methods[key] = ucf.interfaceMethods(t, ifaces)
+			}
+			decl.uses = append(decl.uses, methods[key]...)
+		}
+	}
+
+	used := ucf.usedIdents(decls, owner, topDecl)
+
 	unused := []UnusedObject{}
 	// see which declared idents are not actually used
-	for key, _ := range defined {
-		if _, exists := identToUsage[key]; !exists {
-			unused = append(unused, UnusedObject{
-				Name:     key.Name,
-				Position: p.Fset.Position(key.Pos),
-			})
+	for key, obj := range defined {
+		ucf.declared = append(ucf.declared, obj)
+		if !used[key] && !ucf.isKept(key.Pos) && !exported[key] {
+			unused = append(unused, obj)
 		}
 	}
 	return unused, nil
 }
+
+// usedIdents only counts the uses inside live declarations. A declaration
+// whose package-level identifiers are all unused is dead, so the uses in
+// its body stop counting, which can make more declarations dead. Rather
+// than re-counting until nothing changes, liveness is spread outward from
+// the declarations that are never reported (main, init, tests, blank vars
+// and kept declarations), which reaches the same fixed point and also
+// catches dead cycles like a pair of mutually recursive functions.
+func (ucf *UnusedCodeFinder) usedIdents(decls map[token.Position]*identDecl,
+	owner, topDecl map[ident]token.Position) map[ident]bool {
+
+	used := map[ident]bool{}
+	live := map[token.Position]bool{}
+	queue := []token.Position{}
+	for key, decl := range decls {
+		if decl.root || len(decl.topDefs) == 0 {
+			live[key] = true
+			queue = append(queue, key)
+		}
+	}
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+		for _, use := range decls[key].uses {
+			used[use] = true
+			if declKey, ok := topDecl[use]; ok && !live[declKey] {
+				live[declKey] = true
+				queue = append(queue, declKey)
+			}
+		}
+	}
+
+	// dead declarations get reported as a whole, so the parameters and
+	// locals they use themselves shouldn't be reported on top of that
+	for key, decl := range decls {
+		if live[key] {
+			continue
+		}
+		for _, use := range decl.uses {
+			if owner[use] == key {
+				used[use] = true
+			}
+		}
+	}
+	return used
+}
+
+// topLevelNames maps the package-level identifiers a declaration
+// declares to the node (func or spec) declaring them
+func topLevelNames(d ast.Decl) map[*ast.Ident]ast.Node {
+	names := map[*ast.Ident]ast.Node{}
+	switch d := d.(type) {
+	case *ast.FuncDecl:
+		names[d.Name] = d
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch spec := spec.(type) {
+			case *ast.ValueSpec:
+				for _, name := range spec.Names {
+					names[name] = spec
+				}
+			case *ast.TypeSpec:
+				names[spec.Name] = spec
+			}
+		}
+	}
+	return names
+}
+
+// paramNames returns the receivers, parameters and named results of
+// every function and function literal in a declaration
+func paramNames(d ast.Decl) map[*ast.Ident]bool {
+	params := map[*ast.Ident]bool{}
+	add := func(fields *ast.FieldList) {
+		if fields == nil {
+			return
+		}
+		for _, field := range fields.List {
+			for _, name := range field.Names {
+				params[name] = true
+			}
+		}
+	}
+	ast.Inspect(d, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			add(n.Recv)
+		case *ast.FuncType:
+			add(n.Params)
+			add(n.Results)
+		}
+		return true
+	})
+	return params
+}
+
+// fieldNodes maps the names of every struct field in a declaration to
+// the field declaring them
+func fieldNodes(d ast.Decl) map[*ast.Ident]*ast.Field {
+	fields := map[*ast.Ident]*ast.Field{}
+	ast.Inspect(d, func(n ast.Node) bool {
+		if st, ok := n.(*ast.StructType); ok {
+			for _, field := range st.Fields.List {
+				for _, name := range field.Names {
+					fields[name] = field
+				}
+				if len(field.Names) == 0 {
+					if name := embeddedIdent(field); name != nil {
+						fields[name] = field
+					}
+				}
+			}
+		}
+		return true
+	})
+	return fields
+}
+
+// scopeNames maps the identifiers inside a declaration to the name of
+// what they are declared in: the type, for struct fields, or else the
+// function or package-level name around them
+func scopeNames(d ast.Decl, funcName string) map[*ast.Ident]string {
+	scopes := map[*ast.Ident]string{}
+	var walk func(root ast.Node, scope string)
+	walk = func(root ast.Node, scope string) {
+		ast.Inspect(root, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.TypeSpec:
+				// local types qualify their own fields
+				walk(n.Type, n.Name.Name)
+				return false
+			case *ast.Ident:
+				scopes[n] = scope
+			}
+			return true
+		})
+	}
+	switch d := d.(type) {
+	case *ast.FuncDecl:
+		walk(d, funcName)
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				walk(spec.Type, spec.Name.Name)
+			case *ast.ValueSpec:
+				walk(spec, spec.Names[0].Name)
+			}
+		}
+	}
+	return scopes
+}
@@ -2,6 +2,9 @@ package unused
 
 import (
 	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"os"
+	"sort"
 	"testing"
 )
 
@@ -12,7 +15,7 @@ func TestUnusedIdentsWithMain(t *testing.T) {
 		ucf.Idents = true
 
 		Convey("running 'idents'", func() {
-			results, err := ucf.Run([]string{"testdata"})
+			results, err := ucf.Run(testdataPkgs)
 			So(err, ShouldBeNil)
 
 			Convey("all idents in pkg1, pkg2, and main should be found", func() {
@@ -32,8 +35,93 @@ func TestUnusedIdentsWithMain(t *testing.T) {
 				So("init", ShouldNotBeFoundIn, results)
 			})
 
-			Convey("but funcs that are called in other unused funcs will not be found", func() {
-				So("toUint", ShouldNotBeFoundIn, results)
+			Convey("but not methods called through an interface their type is converted to", func() {
+				So("Litter", ShouldNotBeFoundIn, results)
+				So("(Litter).String", ShouldNotBeFoundIn, results)
+				So("(calico).Meow", ShouldBeFoundIn, results)
+			})
+
+			Convey("results should describe the kind, package and extent of each declaration", func() {
+				byName := map[string]UnusedObject{}
+				for _, o := range results {
+					byName[o.Name] = o
+				}
+				So(byName["Number"].Kind, ShouldEqual, KindConst)
+				So(byName["AnotherNumber"].Kind, ShouldEqual, KindVar)
+				So(byName["unusedType"].Kind, ShouldEqual, KindType)
+				So(byName["oldHelper"].Kind, ShouldEqual, KindFunc)
+				So(byName["(unusedType).Val"].Kind, ShouldEqual, KindMethod)
+				So(byName["field"].Kind, ShouldEqual, KindField)
+				So(byName["unusedParam"].Kind, ShouldEqual, KindParam)
+				So(byName["ut"].Kind, ShouldEqual, KindParam)
+				So(byName["oldHelper"].Package, ShouldEqual, "github.com/3rf/codecoroner/unused/testdata")
+				So(byName["oldHelper"].Position.Line, ShouldEqual, 15)
+				So(byName["oldHelper"].End.Line, ShouldEqual, 17)
+			})
+
+			Convey("and the extent of each declaration should include its doc comment", func() {
+				byName := map[string]UnusedObject{}
+				for _, o := range results {
+					byName[o.Name] = o
+				}
+				So(byName["oldHelper"].Start.Line, ShouldEqual, 13)
+				So(byName["oldHelper"].Lines(), ShouldEqual, 5)
+				// a lone type spec starts at its type keyword's doc comment
+				So(byName["unusedType"].Position.Line, ShouldEqual, 12)
+				So(byName["unusedType"].Start.Line, ShouldEqual, 11)
+				So(byName["unusedType"].Lines(), ShouldEqual, 2)
+				So(byName["unusedParam"].Lines(), ShouldEqual, 1)
+				// fields start at their own doc comment
+				So(byName["Color"].Start.Line, ShouldEqual, 30)
+				So(byName["Color"].Lines(), ShouldEqual, 2)
+
+				Convey("the same as in 'ssa' mode", func() {
+					ssa := NewUnusedCodeFinder()
+					ssa.SSA = true
+					ssaResults, err := ssa.Run(testdataPkgs)
+					So(err, ShouldBeNil)
+					for _, o := range ssaResults {
+						if o.Name == "Color" || o.Name == "unusedType" || o.Name == "oldHelper" {
+							So(o.Start, ShouldResemble, byName[o.Name].Start)
+							So(o.End, ShouldResemble, byName[o.Name].End)
+						}
+					}
+				})
+
+				sorted := append([]UnusedObject{}, results...)
+				sort.Sort(BySize(sorted))
+				for i := 1; i < len(sorted); i++ {
+					So(sorted[i-1].Lines(), ShouldBeGreaterThanOrEqualTo, sorted[i].Lines())
+				}
+			})
+
+			Convey("and filtering by kind should keep only the requested kinds", func() {
+				kinds, err := ParseKinds("field, param")
+				So(err, ShouldBeNil)
+				filtered := FilterKinds(results, kinds)
+				So("field", ShouldBeFoundIn, filtered)
+				So("unusedParam", ShouldBeFoundIn, filtered)
+				So("oldHelper", ShouldNotBeFoundIn, filtered)
+				So("Number", ShouldNotBeFoundIn, filtered)
+
+				_, err = ParseKinds("func,struct")
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("funcs that are only called by other unused funcs should be found too", func() {
+				So("toUint", ShouldBeFoundIn, results)
+				So("Six", ShouldBeFoundIn, results)
+				So("isEven", ShouldBeFoundIn, results)
+				So("isOdd", ShouldBeFoundIn, results)
+			})
+
+			Convey("but not the parameters those dead funcs use themselves", func() {
+				names := []string{}
+				for _, o := range results {
+					names = append(names, o.Name)
+				}
+				So(names, ShouldNotContain, "i")
+				So(names, ShouldNotContain, "n")
 			})
 		})
 	})
@@ -47,7 +135,7 @@ func TestUnusedIdentsWithTests(t *testing.T) {
 		ucf.IncludeTests = true
 
 		Convey("running 'idents' with -tests", func() {
-			results, err := ucf.Run([]string{"testdata"})
+			results, err := ucf.Run(testdataPkgs)
 			So(err, ShouldBeNil)
 
 			Convey("all idents in pkg1, pkg2, and main should be found", func() {
@@ -64,17 +152,71 @@ func TestUnusedIdentsWithTests(t *testing.T) {
 				So("GenIntMod400", ShouldNotBeFoundIn, results)
 				So("ColorKittenLink", ShouldNotBeFoundIn, results)
 				So("init", ShouldNotBeFoundIn, results)
-				So("toUint", ShouldNotBeFoundIn, results)
+				So("toUint", ShouldBeFoundIn, results)
 
 				Convey("plus idents only found in tests", func() {
 					So("testhelper", ShouldBeFoundIn, results)
 					So("GenSix", ShouldNotBeFoundIn, results)
+					So("Six", ShouldNotBeFoundIn, results)
+				})
+
+				Convey("but not benchmarks, examples or fuzz tests, nor the helpers they call", func() {
+					So("BenchmarkGenInt", ShouldNotBeFoundIn, results)
+					So("benchhelper", ShouldNotBeFoundIn, results)
+					So("ExampleGenSix", ShouldNotBeFoundIn, results)
+					So("examplehelper", ShouldNotBeFoundIn, results)
+					So("FuzzGenIntMod400", ShouldNotBeFoundIn, results)
+					So("fuzzhelper", ShouldNotBeFoundIn, results)
 				})
 			})
 		})
 	})
 }
 
+func TestUnusedIdentsInLibraryMode(t *testing.T) {
+	Convey("with a UnusedCodeFinder in library mode", t, func() {
+		ucf := NewUnusedCodeFinder()
+		ucf.Idents = true
+		ucf.Library = true
+
+		Convey("running 'idents' on a package without main", func() {
+			results, err := ucf.Run([]string{"./testdata/pkg1"})
+			So(err, ShouldBeNil)
+
+			Convey("the exported API and what it uses should be live", func() {
+				So("Number", ShouldNotBeFoundIn, results)
+				So("GenSix", ShouldNotBeFoundIn, results)
+				So("toUint", ShouldNotBeFoundIn, results)
+			})
+
+			Convey("but unexported code nothing reaches should be found", func() {
+				So("isEven", ShouldBeFoundIn, results)
+				So("isOdd", ShouldBeFoundIn, results)
+			})
+		})
+
+		Convey("running 'idents' on a generic library", func() {
+			dir, err := ioutil.TempDir("", "generic")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+			So(writeFiles(dir, genericLibrary), ShouldBeNil)
+			ucf.Dir = dir
+			results, err := ucf.Run([]string{"./..."})
+			So(err, ShouldBeNil)
+
+			Convey("exported generic functions and methods should be live", func() {
+				So("Map", ShouldNotBeFoundIn, results)
+				So("(Stack[T]).Push", ShouldNotBeFoundIn, results)
+				So("pushHelper", ShouldNotBeFoundIn, results)
+			})
+
+			Convey("but not what nothing uses", func() {
+				So("unreached", ShouldBeFoundIn, results)
+			})
+		})
+	})
+}
+
 func TestUnusedIdentsWithIgnore(t *testing.T) {
 	Convey("with a test main package and a default UnusedCodeFinder", t, func() {
 		ucf := NewUnusedCodeFinder()
@@ -83,7 +225,7 @@ func TestUnusedIdentsWithIgnore(t *testing.T) {
 		ucf.Ignore = []string{"pkg1", "pkg2"}
 
 		Convey("running 'idents' with -ignore to skip pkg1 and pk2", func() {
-			results, err := ucf.Run([]string{"testdata"})
+			results, err := ucf.Run(testdataPkgs)
 			So(err, ShouldBeNil)
 
 			Convey("only unused idents in main should be found", func() {
@@ -0,0 +1,103 @@
+package unused
+
+import (
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// ImpactReport lists the declarations that would become dead if some
+// functions or main packages were deleted
+type ImpactReport struct {
+	Dead []UnusedObject
+	// Lines is the number of source lines the dead declarations span
+	Lines int
+}
+
+// Impact simulates deleting the given functions or main packages: the
+// functions are cut out of the callgraph and the main packages are
+// dropped from the roots before reachability is computed again. Every
+// function and identifier that is live now but dead afterwards is
+// reported, apart from the deleted functions themselves. Functions are
+// named the way 'whylive' accepts them; main packages by import path or
+// directory.
+func (ucf *UnusedCodeFinder) Impact(removed []string, patterns []string) (*ImpactReport, error) {
+	if err := ucf.load(patterns); err != nil {
+		return nil, err
+	}
+	ucf.Logf("Running callgraph analysis on following packages: \n\t%v",
+		strings.Join(ucf.pkgsAsArray(), "\n\t"))
+	if err := ucf.getCallgraph(); err != nil {
+		return nil, err
+	}
+
+	names := ucf.funcDeclNames()
+	cut := map[*ssa.Function]bool{}
+	deleted := map[token.Position]bool{}
+	// the declarations of a removed main package go away with it
+	deletedPkgs := map[string]bool{}
+	for _, symbol := range removed {
+		found := false
+		for fn := range ucf.callgraph.Nodes {
+			if fn != nil && ucf.isFuncNamed(fn, symbol, names) {
+				cut[fn] = true
+				if key, ok := ucf.funcKey(fn); ok {
+					deleted[key] = true
+				}
+				found = true
+			}
+		}
+		for _, root := range ucf.roots {
+			if root.Pkg != nil && ucf.isMainPackage(root.Pkg, symbol) {
+				cut[root] = true
+				deletedPkgs[root.Pkg.Pkg.Path()] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("'%v' is neither a reachable function nor a main package", symbol)
+		}
+	}
+
+	ucf.Logf("Collecting declarations and references")
+	decls := ucf.collectDeclarationGraph()
+	// walk the same graph both times, so only the cut makes a difference
+	ucf.reachable = reachableFrom(ucf.callgraph, ucf.roots, nil)
+	before := ucf.liveDeclarations(decls)
+
+	ucf.Logf("Recomputing reachability without %v", strings.Join(removed, ", "))
+	ucf.reachable = reachableFrom(ucf.callgraph, ucf.roots, cut)
+	after := ucf.liveDeclarations(decls)
+
+	report := &ImpactReport{}
+	for pos, d := range decls {
+		if !before[pos] || after[pos] || deleted[pos] || deletedPkgs[d.Pkg.PkgPath] ||
+			skipDeclName(d.Obj.Name()) {
+			continue
+		}
+		report.Dead = append(report.Dead, ucf.declObject(d))
+	}
+	report.Lines = lineCount(report.Dead)
+	return report, nil
+}
+
+// isMainPackage reports whether pkg is a main package named by its
+// import path or by its directory
+func (ucf *UnusedCodeFinder) isMainPackage(pkg *ssa.Package, name string) bool {
+	if pkg.Pkg.Name() != "main" {
+		return false
+	}
+	if pkg.Pkg.Path() == name {
+		return true
+	}
+	for _, fn := range pkg.Members {
+		if fn, ok := fn.(*ssa.Function); ok && fn.Pos().IsValid() {
+			dir, err := filepath.Abs(filepath.Join(ucf.Dir, name))
+			return err == nil && filepath.Dir(ucf.fset.Position(fn.Pos()).Filename) == dir
+		}
+	}
+	return false
+}
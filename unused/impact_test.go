@@ -0,0 +1,72 @@
+package unused
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestImpact(t *testing.T) {
+	Convey("with a test main package and a UnusedCodeFinder", t, func() {
+		ucf := NewUnusedCodeFinder()
+		So(ucf, ShouldNotBeNil)
+
+		Convey("removing a function", func() {
+			report, err := ucf.Impact([]string{"pkg2.ColorKittenLink"}, testdataPkgs)
+			So(err, ShouldBeNil)
+
+			Convey("should report what only it kept alive", func() {
+				So("GenIntMod400", ShouldBeFoundIn, report.Dead)
+				So(report.Lines, ShouldBeGreaterThan, 0)
+			})
+
+			Convey("but not the function itself, or what is still used", func() {
+				So("ColorKittenLink", ShouldNotBeFoundIn, report.Dead)
+				So("GenInt", ShouldNotBeFoundIn, report.Dead)
+			})
+
+			Convey("or what was already dead", func() {
+				So("GrayKittenLink", ShouldNotBeFoundIn, report.Dead)
+				So("oldHelper", ShouldNotBeFoundIn, report.Dead)
+			})
+		})
+
+		Convey("removing the main package", func() {
+			report, err := ucf.Impact([]string{"./testdata"}, testdataPkgs)
+			So(err, ShouldBeNil)
+
+			Convey("should report everything it reached", func() {
+				So("GenInt", ShouldBeFoundIn, report.Dead)
+				So("ColorKittenLink", ShouldBeFoundIn, report.Dead)
+				So("(Tabby).Meow", ShouldBeFoundIn, report.Dead)
+				So("Purr", ShouldBeFoundIn, report.Dead)
+			})
+		})
+
+		Convey("removing one of several main packages", func() {
+			report, err := ucf.Impact([]string{"./testdata/bins/alpha"}, []string{"./testdata/bins/..."})
+			So(err, ShouldBeNil)
+
+			Convey("should report the shared code only it used", func() {
+				So("Legacy", ShouldBeFoundIn, report.Dead)
+				So("Greet", ShouldNotBeFoundIn, report.Dead)
+			})
+
+			Convey("but not its own helpers, which are deleted with it", func() {
+				So("banner", ShouldNotBeFoundIn, report.Dead)
+			})
+		})
+
+		Convey("removing something that isn't there", func() {
+			_, err := ucf.Impact([]string{"noSuchFunc"}, testdataPkgs)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestCount(t *testing.T) {
+	Convey("counting declarations and lines for the impact summary", t, func() {
+		So(Count(0, "declaration"), ShouldEqual, "0 declarations")
+		So(Count(1, "declaration"), ShouldEqual, "1 declaration")
+		So(Count(5, "line"), ShouldEqual, "5 lines")
+	})
+}
@@ -0,0 +1,114 @@
+package unused
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// BuildConfig is a single GOOS/GOARCH/build tag combination
+// used for matrix analysis
+type BuildConfig struct {
+	GOOS   string
+	GOARCH string
+	Tags   []string
+}
+
+// ParseBuildConfig reads a configuration in "GOOS/GOARCH" or
+// "GOOS/GOARCH/tag1,tag2" form.
+func ParseBuildConfig(s string) (BuildConfig, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return BuildConfig{}, fmt.Errorf(
+			"invalid build configuration %q; must be GOOS/GOARCH or GOOS/GOARCH/tags", s)
+	}
+	bc := BuildConfig{GOOS: parts[0], GOARCH: parts[1]}
+	if len(parts) == 3 && parts[2] != "" {
+		bc.Tags = strings.Split(parts[2], ",")
+	}
+	return bc, nil
+}
+
+// String prints the configuration in the same form ParseBuildConfig reads.
+func (bc BuildConfig) String() string {
+	s := bc.GOOS + "/" + bc.GOARCH
+	if len(bc.Tags) > 0 {
+		s += "/" + strings.Join(bc.Tags, ",")
+	}
+	return s
+}
+
+// matrixKey identifies the same declaration across configurations
+func matrixKey(o UnusedObject) string {
+	return o.Position.String() + " " + o.Name
+}
+
+// runMatrix runs the analysis once per build configuration. Objects that
+// are dead in every configuration that compiles them are reported as is;
+// objects that are live in some configurations are reported with a
+// label listing where they are still used.
+func (ucf *UnusedCodeFinder) runMatrix(patterns []string) ([]UnusedObject, error) {
+	type matrixEntry struct {
+		obj    UnusedObject
+		deadIn map[string]bool
+	}
+	entries := map[string]*matrixEntry{}
+	order := []string{}
+	filesByConfig := map[string]map[string]bool{}
+	declared := map[string]bool{}
+
+	for _, bc := range ucf.Matrix {
+		ucf.Logf("Analyzing build configuration %v", bc)
+		sub := ucf.clone()
+		sub.Matrix = nil
+		sub.Since = ""
+		sub.BuildTags = append(append([]string{}, ucf.BuildTags...), bc.Tags...)
+		sub.env = append(os.Environ(), "GOOS="+bc.GOOS, "GOARCH="+bc.GOARCH)
+		results, err := sub.Run(patterns)
+		if err != nil {
+			return nil, fmt.Errorf("error analyzing %v: %v", bc, err)
+		}
+		filesByConfig[bc.String()] = sub.files
+		// summaries count each declaration once, whichever
+		// configurations compile it
+		for _, o := range sub.declared {
+			if key := matrixKey(o); !declared[key] {
+				declared[key] = true
+				ucf.declared = append(ucf.declared, o)
+			}
+		}
+		for pkgPath := range sub.pkgs {
+			ucf.pkgs[pkgPath] = struct{}{}
+		}
+		for pkgPath, files := range sub.fileLines {
+			for filename, lines := range files {
+				ucf.addFileLines(pkgPath, filename, lines)
+			}
+		}
+		for _, o := range results {
+			key := matrixKey(o)
+			entry, ok := entries[key]
+			if !ok {
+				entry = &matrixEntry{obj: o, deadIn: map[string]bool{}}
+				entries[key] = entry
+				order = append(order, key)
+			}
+			entry.deadIn[bc.String()] = true
+		}
+	}
+
+	unused := []UnusedObject{}
+	for _, key := range order {
+		entry := entries[key]
+		o := entry.obj
+		for _, bc := range ucf.Matrix {
+			// a configuration that doesn't build the file has no say
+			config := bc.String()
+			if !entry.deadIn[config] && filesByConfig[config][o.Position.Filename] {
+				o.LiveIn = append(o.LiveIn, config)
+			}
+		}
+		unused = append(unused, o)
+	}
+	return unused, nil
+}
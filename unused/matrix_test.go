@@ -0,0 +1,48 @@
+package unused
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestUnusedFuncsInMatrix(t *testing.T) {
+	Convey("with a platform-specific main package and a build matrix", t, func() {
+		ucf := NewUnusedCodeFinder()
+		So(ucf, ShouldNotBeNil)
+		for _, config := range []string{"linux/amd64", "darwin/arm64", "windows/amd64/debug"} {
+			bc, err := ParseBuildConfig(config)
+			So(err, ShouldBeNil)
+			ucf.Matrix = append(ucf.Matrix, bc)
+		}
+
+		Convey("running 'funcs'", func() {
+			results, err := ucf.Run([]string{"./testdata/platform"})
+			So(err, ShouldBeNil)
+			liveIn := map[string][]string{}
+			for _, o := range results {
+				liveIn[o.Name] = o.LiveIn
+			}
+
+			Convey("code dead in every configuration should be found without a label", func() {
+				So("deadEverywhere", ShouldBeFoundIn, results)
+				So(liveIn["deadEverywhere"], ShouldBeEmpty)
+				So("windowsDebugOnly", ShouldBeFoundIn, results)
+				So(liveIn["windowsDebugOnly"], ShouldBeEmpty)
+			})
+
+			Convey("code live in only some configurations should be labeled", func() {
+				So(liveIn["linuxOnly"], ShouldResemble, []string{"linux/amd64"})
+				So(liveIn["darwinOnly"], ShouldResemble, []string{"darwin/arm64"})
+			})
+		})
+	})
+
+	Convey("build configurations must name a GOOS and GOARCH", t, func() {
+		_, err := ParseBuildConfig("linux")
+		So(err, ShouldNotBeNil)
+		bc, err := ParseBuildConfig("linux/arm/debug,trace")
+		So(err, ShouldBeNil)
+		So(bc.Tags, ShouldResemble, []string{"debug", "trace"})
+		So(bc.String(), ShouldEqual, "linux/arm/debug,trace")
+	})
+}
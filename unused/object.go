@@ -1,20 +1,115 @@
 package unused
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"go/token"
+	"go/types"
+	"strings"
 )
 
+// the kinds of declaration an unused object can be
+const (
+	KindFunc   = "func"
+	KindMethod = "method"
+	KindConst  = "const"
+	KindVar    = "var"
+	KindType   = "type"
+	KindField  = "field"
+	KindParam  = "param"
+)
+
+// Kinds lists every kind of unused object
+var Kinds = []string{
+	KindFunc, KindMethod, KindConst, KindVar, KindType, KindField, KindParam}
+
 // UnusedThing represents a found unused function or identifier
 type UnusedObject struct {
 	Name     string
+	Kind     string
 	Position token.Position
+	// Start and End are the extent of the object's declaration,
+	// including its doc comment
+	Start token.Position
+	End   token.Position
+	// Package is the import path of the package declaring the object
+	Package string
+	// Module is the path of the module declaring the object,
+	// which is only filled in for workspace analysis
+	Module string
+	// LiveIn lists the build configurations of a matrix analysis
+	// in which the object is still used
+	LiveIn []string
+
+	// display is the shortened filename, worked out once when the
+	// object is created
+	display string
+	// scope names what a field, parameter or local is declared in, like
+	// its struct type or function, to tell apart objects sharing a name
+	scope string
+}
+
+// File is the object's file, shortened the same way as in String
+func (ut UnusedObject) File() string {
+	if ut.display != "" {
+		return ut.display
+	}
+	return trimGopath(ut.Position.Filename)
+}
+
+// Lines is the number of source lines deleting the object's
+// declaration removes
+func (ut UnusedObject) Lines() int {
+	if !ut.Start.IsValid() || ut.End.Line < ut.Start.Line {
+		return 0
+	}
+	return ut.End.Line - ut.Start.Line + 1
+}
+
+// Count formats n and the noun, adding an "s" unless n is 1,
+// as in "1 line" or "3 lines"
+func Count(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
 }
 
 // String prints the position and name of the unused object.
 func (ut UnusedObject) String() string {
-	return fmt.Sprintf("%v:%v:%v: %v",
-		trimGopath(ut.Position.Filename), ut.Position.Line, ut.Position.Column, ut.Name)
+	s := fmt.Sprintf("%v:%v:%v: %v",
+		ut.File(), ut.Position.Line, ut.Position.Column, ut.Name)
+	if n := ut.Lines(); n > 0 {
+		s += " (" + Count(n, "line") + ")"
+	}
+	if len(ut.LiveIn) > 0 {
+		s += fmt.Sprintf(" (live only in %v)", strings.Join(ut.LiveIn, ", "))
+	}
+	return s
+}
+
+// QualifiedName is the name qualified with what the object is declared
+// in, like "T.field" for a struct field or "Func.param" for a parameter.
+// Package-level names are already unique, so they are left as is.
+func (ut UnusedObject) QualifiedName() string {
+	if ut.scope == "" {
+		return ut.Name
+	}
+	return ut.scope + "." + ut.Name
+}
+
+// Fingerprint identifies an object across runs. It's built from the
+// package, kind and qualified name rather than the position, so it
+// survives unrelated edits that move the declaration around.
+func (ut UnusedObject) Fingerprint() string {
+	sum := sha256.Sum256([]byte(ut.Package + "\x00" + ut.Kind + "\x00" + ut.QualifiedName()))
+	return hex.EncodeToString(sum[:16])
+}
+
+// RuleID names the kind of finding, like "unused-func"
+func (ut UnusedObject) RuleID() string {
+	return "unused-" + ut.Kind
 }
 
 // ByPosition sorts unused objects by file/location.
@@ -41,6 +136,83 @@ func (p ByPosition) Less(i, j int) bool {
 		return oi.Column < oj.Column
 	}
 
-	// it's a bug if this even needs to be used
+	// closures share the position of their enclosing declaration
 	return p[i].Name < p[j].Name
 }
+
+// BySize sorts unused objects from the largest declaration to the
+// smallest, so the biggest cleanups come first. Objects of the same
+// size keep the ByPosition order.
+type BySize []UnusedObject
+
+// Len method for sorting
+func (p BySize) Len() int { return len(p) }
+
+// Swap method for sorting
+func (p BySize) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+// Less method for sorting on the line count
+func (p BySize) Less(i, j int) bool {
+	if li, lj := p[i].Lines(), p[j].Lines(); li != lj {
+		return li > lj
+	}
+	return ByPosition(p).Less(i, j)
+}
+
+// kindOf returns the kind of declaration obj is. Parameters can't be
+// told apart from local variables by their object alone, so those are
+// left to the caller.
+func kindOf(obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.Func:
+		if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+			return KindMethod
+		}
+		return KindFunc
+	case *types.Const:
+		return KindConst
+	case *types.TypeName:
+		return KindType
+	case *types.Var:
+		if obj.IsField() {
+			return KindField
+		}
+	}
+	return KindVar
+}
+
+// ParseKinds splits a comma-separated list of kinds, checking that each
+// one is known
+func ParseKinds(list string) ([]string, error) {
+	kinds := []string{}
+	for _, kind := range strings.Split(list, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind == "" {
+			continue
+		}
+		known := false
+		for _, k := range Kinds {
+			known = known || k == kind
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown kind '%v'; must be one of %v",
+				kind, strings.Join(Kinds, ", "))
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+// FilterKinds returns the objects whose kind is one of kinds
+func FilterKinds(unusedObjects []UnusedObject, kinds []string) []UnusedObject {
+	filtered := []UnusedObject{}
+	for _, o := range unusedObjects {
+		for _, kind := range kinds {
+			if o.Kind == kind {
+				filtered = append(filtered, o)
+				break
+			}
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,155 @@
+package unused
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ReportInfo describes the analysis whose results are being reported
+type ReportInfo struct {
+	// Mode is the analysis that was run: "funcs", "idents" or "ssa"
+	Mode string
+	// Packages holds the import paths of every analyzed package,
+	// including those without any unused objects
+	Packages []string
+	// Workspace is set for workspace analysis, whose results are
+	// grouped by module
+	Workspace bool
+}
+
+// Reporter prints the results of an analysis in some format. Start is
+// called once before any objects are reported, and Finish once after.
+type Reporter interface {
+	Start(info ReportInfo) error
+	Report(o UnusedObject) error
+	Finish() error
+}
+
+// ReporterFactory creates a reporter that writes to w
+type ReporterFactory func(w io.Writer) Reporter
+
+var (
+	reportersMu sync.Mutex
+	reporters   = map[string]ReporterFactory{}
+)
+
+// RegisterReporter makes a format available by name, replacing any
+// format already registered under that name
+func RegisterReporter(name string, factory ReporterFactory) {
+	reportersMu.Lock()
+	defer reportersMu.Unlock()
+	reporters[name] = factory
+}
+
+// unregisterReporter removes a format, so tests can clean up after
+// registering their own
+func unregisterReporter(name string) {
+	reportersMu.Lock()
+	defer reportersMu.Unlock()
+	delete(reporters, name)
+}
+
+// NewReporter creates a reporter for the named format
+func NewReporter(name string, w io.Writer) (Reporter, error) {
+	reportersMu.Lock()
+	defer reportersMu.Unlock()
+	factory, ok := reporters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown format '%v'", name)
+	}
+	return factory(w), nil
+}
+
+// ReporterNames returns the sorted names of every registered format
+func ReporterNames() []string {
+	reportersMu.Lock()
+	defer reportersMu.Unlock()
+	names := make([]string, 0, len(reporters))
+	for name := range reporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteReport reports every object with r, in the given order
+func WriteReport(r Reporter, info ReportInfo, unusedObjects []UnusedObject) error {
+	if err := r.Start(info); err != nil {
+		return err
+	}
+	for _, o := range unusedObjects {
+		if err := r.Report(o); err != nil {
+			return err
+		}
+	}
+	return r.Finish()
+}
+
+// relativeTo returns the path of filename relative to root, if the
+// file is inside of it
+func relativeTo(root, filename string) (string, bool) {
+	if root == "" {
+		return "", false
+	}
+	rel, err := filepath.Rel(root, filename)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+func init() {
+	RegisterReporter("text", func(w io.Writer) Reporter { return &textReporter{w: w} })
+}
+
+// textReporter prints each object on its own line, as
+// "file:line:col: name (N lines)", where N is how many lines deleting
+// the declaration removes. Workspace results are grouped under a header
+// for each module, in the style of the go tool's "# pkg" headers.
+type textReporter struct {
+	w         io.Writer
+	workspace bool
+	byModule  map[string][]UnusedObject
+}
+
+func (r *textReporter) Start(info ReportInfo) error {
+	r.workspace = info.Workspace
+	r.byModule = map[string][]UnusedObject{}
+	return nil
+}
+
+func (r *textReporter) Report(o UnusedObject) error {
+	if r.workspace {
+		r.byModule[o.Module] = append(r.byModule[o.Module], o)
+		return nil
+	}
+	return r.print(o)
+}
+
+func (r *textReporter) print(o UnusedObject) error {
+	_, err := fmt.Fprintln(r.w, o.String())
+	return err
+}
+
+func (r *textReporter) Finish() error {
+	modules := make([]string, 0, len(r.byModule))
+	for module := range r.byModule {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	for _, module := range modules {
+		if _, err := fmt.Fprintf(r.w, "# %s\n", module); err != nil {
+			return err
+		}
+		for _, o := range r.byModule[module] {
+			if err := r.print(o); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package unused
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+)
+
+func init() {
+	RegisterReporter("json", func(w io.Writer) Reporter { return &jsonReporter{w: w} })
+	RegisterReporter("ndjson", func(w io.Writer) Reporter { return &jsonReporter{w: w, stream: true} })
+}
+
+// jsonObject is the JSON form of an unused object
+type jsonObject struct {
+	Name        string   `json:"name"`
+	Kind        string   `json:"kind"`
+	Package     string   `json:"package"`
+	Module      string   `json:"module,omitempty"`
+	File        string   `json:"file"`
+	Line        int      `json:"line"`
+	Column      int      `json:"column"`
+	StartLine   int      `json:"start_line"`
+	StartColumn int      `json:"start_column"`
+	EndLine     int      `json:"end_line"`
+	EndColumn   int      `json:"end_column"`
+	Lines       int      `json:"lines"`
+	Mode        string   `json:"mode"`
+	LiveIn      []string `json:"live_in,omitempty"`
+}
+
+// jsonReporter prints the results as a JSON array, or as a stream of
+// newline-delimited JSON objects
+type jsonReporter struct {
+	w      io.Writer
+	stream bool
+	mode   string
+	root   string
+	objs   []jsonObject
+}
+
+func (r *jsonReporter) Start(info ReportInfo) error {
+	r.mode = info.Mode
+	r.root, _ = os.Getwd()
+	r.objs = []jsonObject{}
+	return nil
+}
+
+func (r *jsonReporter) Report(o UnusedObject) error {
+	obj := jsonObject{
+		Name:        o.Name,
+		Kind:        o.Kind,
+		Package:     o.Package,
+		Module:      o.Module,
+		File:        r.file(o.Position.Filename),
+		Line:        o.Position.Line,
+		Column:      o.Position.Column,
+		StartLine:   o.Start.Line,
+		StartColumn: o.Start.Column,
+		EndLine:     o.End.Line,
+		EndColumn:   o.End.Column,
+		Lines:       o.Lines(),
+		Mode:        r.mode,
+		LiveIn:      o.LiveIn,
+	}
+	if r.stream {
+		return json.NewEncoder(r.w).Encode(obj)
+	}
+	r.objs = append(r.objs, obj)
+	return nil
+}
+
+func (r *jsonReporter) Finish() error {
+	if r.stream {
+		return nil
+	}
+	enc := json.NewEncoder(r.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.objs)
+}
+
+// file gives a filename relative to the current directory, like the go
+// tool does, so scripts can open it; files elsewhere keep their
+// absolute path
+func (r *jsonReporter) file(filename string) string {
+	if rel, ok := relativeTo(r.root, filename); ok {
+		return rel
+	}
+	return filename
+}
@@ -0,0 +1,168 @@
+package unused
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// the SARIF version written by WriteSARIF
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// the subset of SARIF 2.1.0 that codecoroner fills in
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+func init() {
+	RegisterReporter("sarif", func(w io.Writer) Reporter { return &sarifReporter{w: w} })
+}
+
+// WriteSARIF writes the results of an analysis in the given mode as a
+// SARIF 2.1.0 log
+func WriteSARIF(w io.Writer, unusedObjects []UnusedObject, mode string) error {
+	return WriteReport(&sarifReporter{w: w}, ReportInfo{Mode: mode}, unusedObjects)
+}
+
+// sarifReporter collects the results into a single SARIF run, with a
+// rule per kind of declaration. Files inside the current directory are
+// given relative to it (the source root), the rest as absolute file URIs.
+type sarifReporter struct {
+	w     io.Writer
+	mode  string
+	root  string
+	rules map[string]bool
+	run   sarifRun
+}
+
+func (r *sarifReporter) Start(info ReportInfo) error {
+	r.mode = info.Mode
+	r.root, _ = os.Getwd()
+	r.rules = map[string]bool{}
+	r.run = sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "codecoroner",
+			InformationURI: "https://github.com/3rf/codecoroner",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+	return nil
+}
+
+func (r *sarifReporter) Report(o UnusedObject) error {
+	if !r.rules[o.RuleID()] {
+		r.rules[o.RuleID()] = true
+		r.run.Tool.Driver.Rules = append(r.run.Tool.Driver.Rules, sarifRule{
+			ID:               o.RuleID(),
+			ShortDescription: sarifMessage{Text: fmt.Sprintf("unused %v", o.Kind)},
+		})
+	}
+	// the region runs from the start of the doc comment to the end of
+	// the declaration, the same extent deleting it would remove
+	start := o.Start
+	if !start.IsValid() {
+		start = o.Position
+	}
+	r.run.Results = append(r.run.Results, sarifResult{
+		RuleID:  o.RuleID(),
+		Level:   "warning",
+		Message: sarifMessage{Text: fmt.Sprintf("%v is unused (found by '%v' analysis)", o.Name, r.mode)},
+		Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifact(r.root, o.Position.Filename),
+			Region: sarifRegion{
+				StartLine:   start.Line,
+				StartColumn: start.Column,
+				EndLine:     o.End.Line,
+				EndColumn:   o.End.Column,
+			},
+		}}},
+		PartialFingerprints: map[string]string{"codecoroner/v1": o.Fingerprint()},
+	})
+	return nil
+}
+
+func (r *sarifReporter) Finish() error {
+	sort.Slice(r.run.Tool.Driver.Rules, func(i, j int) bool {
+		return r.run.Tool.Driver.Rules[i].ID < r.run.Tool.Driver.Rules[j].ID
+	})
+	enc := json.NewEncoder(r.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{r.run},
+	})
+}
+
+// sarifArtifact locates a file relative to the source root if it's
+// inside of it
+func sarifArtifact(root, filename string) sarifArtifactLocation {
+	if rel, ok := relativeTo(root, filename); ok {
+		return sarifArtifactLocation{URI: filepath.ToSlash(rel), URIBaseID: "%SRCROOT%"}
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(filename)}
+	return sarifArtifactLocation{URI: u.String()}
+}
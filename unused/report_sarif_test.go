@@ -0,0 +1,66 @@
+package unused
+
+import (
+	"bytes"
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+)
+
+func TestSARIF(t *testing.T) {
+	Convey("with the results of a 'ssa' analysis", t, func() {
+		ucf := NewUnusedCodeFinder()
+		ucf.SSA = true
+		results, err := ucf.Run(testdataPkgs)
+		So(err, ShouldBeNil)
+
+		Convey("writing them as SARIF", func() {
+			buf := &bytes.Buffer{}
+			So(WriteSARIF(buf, results, ucf.Mode()), ShouldBeNil)
+			log := sarifLog{}
+			So(json.Unmarshal(buf.Bytes(), &log), ShouldBeNil)
+
+			Convey("should produce a single 2.1.0 run with a result per object", func() {
+				So(log.Version, ShouldEqual, "2.1.0")
+				So(len(log.Runs), ShouldEqual, 1)
+				So(len(log.Runs[0].Results), ShouldEqual, len(results))
+			})
+
+			Convey("with a rule per kind of declaration", func() {
+				ids := []string{}
+				for _, rule := range log.Runs[0].Tool.Driver.Rules {
+					ids = append(ids, rule.ID)
+				}
+				So(ids, ShouldResemble, []string{"unused-const", "unused-field",
+					"unused-func", "unused-method", "unused-type", "unused-var"})
+			})
+
+			Convey("and regions spanning each declaration", func() {
+				for _, r := range log.Runs[0].Results {
+					if strings.HasPrefix(r.Message.Text, "oldHelper ") {
+						region := r.Locations[0].PhysicalLocation.Region
+						So(r.RuleID, ShouldEqual, "unused-func")
+						// from the doc comment to the closing brace
+						So(region.StartLine, ShouldEqual, 13)
+						So(region.StartColumn, ShouldEqual, 1)
+						So(region.EndLine, ShouldEqual, 17)
+						So(region.EndColumn, ShouldEqual, 2)
+						So(r.Locations[0].PhysicalLocation.ArtifactLocation.URI,
+							ShouldEqual, "testdata/mockmain.go")
+					}
+				}
+			})
+		})
+
+		Convey("fingerprints should not depend on the position", func() {
+			o := results[0]
+			moved := o
+			moved.Position.Line += 10
+			So(moved.Fingerprint(), ShouldEqual, o.Fingerprint())
+			renamed := o
+			renamed.Name += "2"
+			So(renamed.Fingerprint(), ShouldNotEqual, o.Fingerprint())
+		})
+	})
+}
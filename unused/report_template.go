@@ -0,0 +1,38 @@
+package unused
+
+import (
+	"io"
+	"strings"
+	"text/template"
+)
+
+// templateReporter prints each object with a text/template, in the
+// style of 'go list -f'. The template is executed with the UnusedObject
+// itself, and a newline is added after every object.
+type templateReporter struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+// NewTemplateReporter parses the template used to print each object
+func NewTemplateReporter(w io.Writer, text string) (Reporter, error) {
+	tmpl, err := template.New("format").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &templateReporter{w: w, tmpl: tmpl}, nil
+}
+
+func (r *templateReporter) Start(info ReportInfo) error { return nil }
+
+func (r *templateReporter) Report(o UnusedObject) error {
+	if err := r.tmpl.Execute(r.w, o); err != nil {
+		return err
+	}
+	_, err := io.WriteString(r.w, "\n")
+	return err
+}
+
+func (r *templateReporter) Finish() error { return nil }
@@ -0,0 +1,117 @@
+package unused
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// countingReporter is a custom format that only counts the objects
+type countingReporter struct {
+	w     io.Writer
+	count int
+}
+
+func (r *countingReporter) Start(info ReportInfo) error { return nil }
+func (r *countingReporter) Report(o UnusedObject) error { r.count++; return nil }
+func (r *countingReporter) Finish() error {
+	_, err := fmt.Fprintf(r.w, "found %v", r.count)
+	return err
+}
+
+func TestReporters(t *testing.T) {
+	Convey("with a few unused objects from two modules", t, func() {
+		objs := []UnusedObject{
+			{Name: "Shout", Kind: KindFunc, Module: "example.com/libs",
+				Position: token.Position{Filename: "/src/libs/strs/strs.go", Line: 5, Column: 1}},
+			{Name: "unusedFlag", Kind: KindVar, Module: "example.com/cmd",
+				Position: token.Position{Filename: "/src/cmd/main.go", Line: 11, Column: 5}},
+		}
+		buf := &bytes.Buffer{}
+
+		Convey("the built-in formats should all be registered", func() {
+			for _, name := range []string{"text", "json", "ndjson", "sarif", "checkstyle", "junit"} {
+				So(ReporterNames(), ShouldContain, name)
+			}
+		})
+
+		Convey("the text reporter should print how many lines each declaration spans", func() {
+			objs[0].Start = token.Position{Filename: "/src/libs/strs/strs.go", Line: 4, Column: 1}
+			objs[0].End = token.Position{Filename: "/src/libs/strs/strs.go", Line: 7, Column: 2}
+			r, err := NewReporter("text", buf)
+			So(err, ShouldBeNil)
+			So(WriteReport(r, ReportInfo{Mode: "funcs"}, objs[:1]), ShouldBeNil)
+			So(buf.String(), ShouldEqual, "/src/libs/strs/strs.go:5:1: Shout (4 lines)\n")
+		})
+
+		Convey("the text reporter should print one line per object", func() {
+			r, err := NewReporter("text", buf)
+			So(err, ShouldBeNil)
+			So(WriteReport(r, ReportInfo{Mode: "funcs"}, objs), ShouldBeNil)
+			So(buf.String(), ShouldEqual,
+				"/src/libs/strs/strs.go:5:1: Shout\n/src/cmd/main.go:11:5: unusedFlag\n")
+		})
+
+		Convey("and group them by module for workspaces", func() {
+			r, err := NewReporter("text", buf)
+			So(err, ShouldBeNil)
+			So(WriteReport(r, ReportInfo{Mode: "funcs", Workspace: true}, objs), ShouldBeNil)
+			So(buf.String(), ShouldEqual, "# example.com/cmd\n/src/cmd/main.go:11:5: unusedFlag\n"+
+				"# example.com/libs\n/src/libs/strs/strs.go:5:1: Shout\n")
+		})
+
+		Convey("the json reporter should give filenames relative to the current directory", func() {
+			cwd, err := os.Getwd()
+			So(err, ShouldBeNil)
+			objs[0].Position.Filename = filepath.Join(cwd, "testdata", "mockmain.go")
+			r, err := NewReporter("ndjson", buf)
+			So(err, ShouldBeNil)
+			So(WriteReport(r, ReportInfo{Mode: "funcs"}, objs), ShouldBeNil)
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			So(len(lines), ShouldEqual, 2)
+			So(lines[0], ShouldContainSubstring, `"file":"testdata/mockmain.go"`)
+
+			Convey("and keep the absolute path of files outside of it", func() {
+				So(lines[1], ShouldContainSubstring, `"file":"/src/cmd/main.go"`)
+			})
+		})
+
+		Convey("custom formats can be registered", func() {
+			RegisterReporter("count", func(w io.Writer) Reporter { return &countingReporter{w: w} })
+			defer unregisterReporter("count")
+			So(ReporterNames(), ShouldContain, "count")
+			r, err := NewReporter("count", buf)
+			So(err, ShouldBeNil)
+			So(WriteReport(r, ReportInfo{}, objs), ShouldBeNil)
+			So(buf.String(), ShouldEqual, "found 2")
+		})
+
+		Convey("a template reporter should execute the template for each object", func() {
+			r, err := NewTemplateReporter(buf, "{{.Position.Filename}}|{{.Name}}|{{.Kind}}")
+			So(err, ShouldBeNil)
+			So(WriteReport(r, ReportInfo{}, objs), ShouldBeNil)
+			So(buf.String(), ShouldEqual,
+				"/src/libs/strs/strs.go|Shout|func\n/src/cmd/main.go|unusedFlag|var\n")
+		})
+
+		Convey("but not if it doesn't parse", func() {
+			_, err := NewTemplateReporter(buf, "{{.Name")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("unknown formats should be an error", func() {
+			_, err := NewReporter("yaml", buf)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("and registered test formats shouldn't leak into other tests", func() {
+			So(ReporterNames(), ShouldNotContain, "count")
+		})
+	})
+}
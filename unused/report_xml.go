@@ -0,0 +1,146 @@
+package unused
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+func init() {
+	RegisterReporter("checkstyle", func(w io.Writer) Reporter { return &checkstyleReporter{w: w} })
+	RegisterReporter("junit", func(w io.Writer) Reporter { return &junitReporter{w: w} })
+}
+
+// checkstyleFile holds the errors of a single file in Checkstyle XML
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// checkstyleReporter prints the results as Checkstyle XML, grouping the
+// errors by file in the order the files are first reported
+type checkstyleReporter struct {
+	w      io.Writer
+	files  []*checkstyleFile
+	byName map[string]*checkstyleFile
+}
+
+func (r *checkstyleReporter) Start(info ReportInfo) error {
+	r.files = nil
+	r.byName = map[string]*checkstyleFile{}
+	return nil
+}
+
+func (r *checkstyleReporter) Report(o UnusedObject) error {
+	file, ok := r.byName[o.Position.Filename]
+	if !ok {
+		file = &checkstyleFile{Name: o.Position.Filename}
+		r.byName[file.Name] = file
+		r.files = append(r.files, file)
+	}
+	file.Errors = append(file.Errors, checkstyleError{
+		Line:     o.Position.Line,
+		Column:   o.Position.Column,
+		Severity: "warning",
+		Message:  fmt.Sprintf("%v is unused", o.Name),
+		Source:   "codecoroner." + o.RuleID(),
+	})
+	return nil
+}
+
+func (r *checkstyleReporter) Finish() error {
+	return writeXML(r.w, struct {
+		XMLName xml.Name          `xml:"checkstyle"`
+		Version string            `xml:"version,attr"`
+		Files   []*checkstyleFile `xml:"file"`
+	}{Version: "4.3", Files: r.files})
+}
+
+// junitCase is a single package's testcase in JUnit XML
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// junitReporter prints the results as JUnit XML, with a testcase for
+// every analyzed package that fails when the package has dead code
+type junitReporter struct {
+	w         io.Writer
+	mode      string
+	packages  []string
+	byPackage map[string][]UnusedObject
+}
+
+func (r *junitReporter) Start(info ReportInfo) error {
+	r.mode = info.Mode
+	r.packages = append([]string{}, info.Packages...)
+	r.byPackage = map[string][]UnusedObject{}
+	for _, pkg := range r.packages {
+		r.byPackage[pkg] = nil
+	}
+	return nil
+}
+
+func (r *junitReporter) Report(o UnusedObject) error {
+	if _, ok := r.byPackage[o.Package]; !ok {
+		r.packages = append(r.packages, o.Package)
+	}
+	r.byPackage[o.Package] = append(r.byPackage[o.Package], o)
+	return nil
+}
+
+func (r *junitReporter) Finish() error {
+	sort.Strings(r.packages)
+	suite := struct {
+		XMLName  xml.Name    `xml:"testsuite"`
+		Name     string      `xml:"name,attr"`
+		Tests    int         `xml:"tests,attr"`
+		Failures int         `xml:"failures,attr"`
+		Cases    []junitCase `xml:"testcase"`
+	}{Name: "codecoroner " + r.mode}
+	for _, pkg := range r.packages {
+		tc := junitCase{Name: pkg, ClassName: "codecoroner." + r.mode}
+		if found := r.byPackage[pkg]; len(found) > 0 {
+			lines := []string{}
+			for _, o := range found {
+				lines = append(lines, o.String())
+			}
+			tc.Failure = &junitFailure{
+				Message: Count(len(found), "unused declaration"),
+				Type:    "unused",
+				Body:    strings.Join(lines, "\n"),
+			}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Tests = len(suite.Cases)
+	return writeXML(r.w, suite)
+}
+
+// writeXML writes v as an indented XML document
+func writeXML(w io.Writer, v interface{}) error {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, out)
+	return err
+}
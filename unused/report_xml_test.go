@@ -0,0 +1,94 @@
+package unused
+
+import (
+	"bytes"
+	"encoding/xml"
+	. "github.com/smartystreets/goconvey/convey"
+	"go/token"
+	"testing"
+)
+
+func TestXMLReporters(t *testing.T) {
+	Convey("with unused objects in two files, two of them in the same file", t, func() {
+		objs := []UnusedObject{
+			{Name: "Shout", Kind: KindFunc, Package: "example.com/libs/strs",
+				Position: token.Position{Filename: "/src/libs/strs/strs.go", Line: 5, Column: 1}},
+			{Name: "Whisper", Kind: KindFunc, Package: "example.com/libs/strs",
+				Position: token.Position{Filename: "/src/libs/strs/strs.go", Line: 9, Column: 1}},
+			{Name: "unusedFlag", Kind: KindVar, Package: "example.com/cmd",
+				Position: token.Position{Filename: "/src/cmd/main.go", Line: 11, Column: 5}},
+		}
+		buf := &bytes.Buffer{}
+
+		Convey("the checkstyle reporter should group the errors by file", func() {
+			r, err := NewReporter("checkstyle", buf)
+			So(err, ShouldBeNil)
+			So(WriteReport(r, ReportInfo{Mode: "funcs"}, objs), ShouldBeNil)
+
+			var doc struct {
+				Files []checkstyleFile `xml:"file"`
+			}
+			So(xml.Unmarshal(buf.Bytes(), &doc), ShouldBeNil)
+			So(len(doc.Files), ShouldEqual, 2)
+			So(doc.Files[0].Name, ShouldEqual, "/src/libs/strs/strs.go")
+			So(len(doc.Files[0].Errors), ShouldEqual, 2)
+			So(doc.Files[0].Errors[0].Message, ShouldEqual, "Shout is unused")
+			So(doc.Files[0].Errors[1].Line, ShouldEqual, 9)
+			So(doc.Files[0].Errors[1].Source, ShouldEqual, "codecoroner.unused-func")
+			So(doc.Files[1].Name, ShouldEqual, "/src/cmd/main.go")
+			So(len(doc.Files[1].Errors), ShouldEqual, 1)
+			So(doc.Files[1].Errors[0].Source, ShouldEqual, "codecoroner.unused-var")
+		})
+
+		Convey("the junit reporter should fail the testcase of each package with dead code", func() {
+			r, err := NewReporter("junit", buf)
+			So(err, ShouldBeNil)
+			info := ReportInfo{Mode: "funcs", Packages: []string{"example.com/libs/strs", "example.com/cmd"}}
+			So(WriteReport(r, info, objs), ShouldBeNil)
+			So(buf.String(), ShouldContainSubstring, `tests="2" failures="2"`)
+			So(buf.String(), ShouldContainSubstring, `message="2 unused declarations"`)
+			So(buf.String(), ShouldContainSubstring, `message="1 unused declaration"`)
+		})
+
+		Convey("and have a passing testcase for clean packages", func() {
+			r, err := NewReporter("junit", buf)
+			So(err, ShouldBeNil)
+			info := ReportInfo{Mode: "funcs", Packages: []string{"example.com/libs/strs", "example.com/libs/clean"}}
+			So(WriteReport(r, info, objs[:1]), ShouldBeNil)
+			So(buf.String(), ShouldContainSubstring, `tests="2" failures="1"`)
+			So(buf.String(), ShouldContainSubstring, `<testcase name="example.com/libs/clean" classname="codecoroner.funcs"></testcase>`)
+		})
+	})
+}
+
+func TestJUnitReporterWithMatrix(t *testing.T) {
+	Convey("with two binaries analyzed under a build matrix", t, func() {
+		ucf := NewUnusedCodeFinder()
+		for _, config := range []string{"linux/amd64", "darwin/arm64"} {
+			bc, err := ParseBuildConfig(config)
+			So(err, ShouldBeNil)
+			ucf.Matrix = append(ucf.Matrix, bc)
+		}
+		results, err := ucf.Run([]string{"./testdata/bins/..."})
+		So(err, ShouldBeNil)
+
+		Convey("the analyzed packages should come from the configurations", func() {
+			So(ucf.Packages(), ShouldResemble, []string{
+				"github.com/3rf/codecoroner/unused/testdata/bins/alpha",
+				"github.com/3rf/codecoroner/unused/testdata/bins/beta",
+				"github.com/3rf/codecoroner/unused/testdata/bins/shared",
+			})
+		})
+
+		Convey("and the junit reporter should pass the clean ones", func() {
+			buf := &bytes.Buffer{}
+			r, err := NewReporter("junit", buf)
+			So(err, ShouldBeNil)
+			info := ReportInfo{Mode: ucf.Mode(), Packages: ucf.Packages()}
+			So(WriteReport(r, info, results), ShouldBeNil)
+			So(buf.String(), ShouldContainSubstring, `tests="3" failures="1"`)
+			So(buf.String(), ShouldContainSubstring,
+				`<testcase name="github.com/3rf/codecoroner/unused/testdata/bins/alpha" classname="codecoroner.funcs"></testcase>`)
+		})
+	})
+}
@@ -0,0 +1,196 @@
+package unused
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hunkHeader matches the header of a unified diff hunk, capturing the
+// start and length of the new side
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// changedSince limits the results to the declarations that overlap the
+// lines changed since the git ref, plus the declarations that were
+// still live at the ref, which the change made dead wherever they are
+func (ucf *UnusedCodeFinder) changedSince(unusedObjects []UnusedObject,
+	patterns []string) ([]UnusedObject, error) {
+
+	top, err := ucf.git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	top = strings.TrimSpace(top)
+	changed, err := ucf.changedLines(top)
+	if err != nil {
+		return nil, err
+	}
+
+	ucf.Logf("Analyzing %v to find code that became dead since then", ucf.Since)
+	base, err := ucf.analyzeRef(top, patterns)
+	if err != nil {
+		// code that didn't build at the ref can't have been live there,
+		// so fall back to what was touched
+		ucf.Errorf("Error analyzing %v, only reporting changed code: %v", ucf.Since, err)
+		base = nil
+	}
+	newlyDead := map[string]bool{}
+	if base != nil {
+		for _, o := range NewBaseline(base).NewFindings(unusedObjects) {
+			newlyDead[matrixKey(o)] = true
+		}
+	}
+
+	found := []UnusedObject{}
+	for _, o := range unusedObjects {
+		if newlyDead[matrixKey(o)] || changed.overlaps(top, o) {
+			found = append(found, o)
+		}
+	}
+	return found, nil
+}
+
+// lineSet holds the changed lines of each file, by path relative to
+// the repository root; a nil entry means the whole file is new
+type lineSet map[string]map[int]bool
+
+// overlaps reports whether any line of the object's declaration changed
+func (ls lineSet) overlaps(top string, o UnusedObject) bool {
+	filename := o.Position.Filename
+	if resolved, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = resolved
+	}
+	rel, err := filepath.Rel(top, filename)
+	if err != nil {
+		return false
+	}
+	lines, ok := ls[filepath.ToSlash(rel)]
+	if !ok {
+		return false
+	}
+	if lines == nil {
+		return true
+	}
+	for line := o.Start.Line; line <= o.End.Line; line++ {
+		if lines[line] {
+			return true
+		}
+	}
+	return false
+}
+
+// changedLines diffs the working tree against the ref. Untracked files
+// count as changed in full.
+func (ucf *UnusedCodeFinder) changedLines(top string) (lineSet, error) {
+	diff, err := ucf.git("-C", top, "diff", "--no-color", "--no-ext-diff",
+		"--src-prefix=a/", "--dst-prefix=b/", "-U0", ucf.Since, "--")
+	if err != nil {
+		return nil, err
+	}
+	changed := lineSet{}
+	file := ""
+	scanner := bufio.NewScanner(strings.NewReader(diff))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			file = ""
+			if name := strings.TrimPrefix(line, "+++ "); name != "/dev/null" {
+				file = strings.TrimPrefix(name, "b/")
+				changed[file] = map[int]bool{}
+			}
+		case file != "" && strings.HasPrefix(line, "@@ "):
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			start, _ := strconv.Atoi(m[1])
+			count := 1
+			if m[2] != "" {
+				count, _ = strconv.Atoi(m[2])
+			}
+			// a pure deletion still touches the declaration around it
+			if count == 0 {
+				count = 1
+			}
+			for l := start; l < start+count; l++ {
+				changed[file][l] = true
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// NUL-separated, so paths with spaces or quotes come through as is
+	untracked, err := ucf.git("-C", top, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range strings.Split(untracked, "\x00") {
+		if file != "" {
+			changed[file] = nil
+		}
+	}
+	return changed, nil
+}
+
+// analyzeRef runs the same analysis on a temporary worktree checked
+// out at the ref
+func (ucf *UnusedCodeFinder) analyzeRef(top string, patterns []string) ([]UnusedObject, error) {
+	dir, err := ioutil.TempDir("", "codecoroner")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	worktree := filepath.Join(dir, "base")
+	if _, err := ucf.git("-C", top, "worktree", "add", "--detach", worktree, ucf.Since); err != nil {
+		return nil, err
+	}
+	defer ucf.git("-C", top, "worktree", "remove", "--force", worktree)
+
+	// analyze the same directory of the old tree
+	cwd := ucf.Dir
+	if cwd == "" {
+		if cwd, err = os.Getwd(); err != nil {
+			return nil, err
+		}
+	}
+	if cwd, err = filepath.Abs(cwd); err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(cwd); err == nil {
+		cwd = resolved
+	}
+	rel, err := filepath.Rel(top, cwd)
+	if err != nil {
+		return nil, err
+	}
+
+	base := ucf.clone()
+	base.Since = ""
+	base.Dir = filepath.Join(worktree, rel)
+	return base.Run(patterns)
+}
+
+// git runs a git command in the analyzed directory and returns its output
+func (ucf *UnusedCodeFinder) git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = ucf.Dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %v: %v: %v",
+			strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
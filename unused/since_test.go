@@ -0,0 +1,156 @@
+package unused
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes each file's contents under dir
+func writeFiles(dir string, files map[string]string) error {
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gitIn runs a git command in dir
+func gitIn(dir string, args ...string) error {
+	cmd := exec.Command("git", append([]string{
+		"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	return cmd.Run()
+}
+
+func TestUnusedSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	Convey("with a git repository whose working tree changed since HEAD", t, func() {
+		dir, err := ioutil.TempDir("", "since")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		So(writeFiles(dir, map[string]string{
+			"go.mod": "module example.com/since\n",
+			"main.go": `package main
+
+func main() {
+	helper()
+}
+
+func helper() {}
+`,
+			"old.go": `package main
+
+// old was dead before the change
+func old() {}
+
+// stale was dead too, but the change edits it
+func stale() int {
+	return 1
+}
+`,
+		}), ShouldBeNil)
+		So(gitIn(dir, "init", "-q"), ShouldBeNil)
+		So(gitIn(dir, "add", "."), ShouldBeNil)
+		So(gitIn(dir, "commit", "-q", "-m", "initial"), ShouldBeNil)
+
+		// stop calling helper, add a dead func to a tracked file and
+		// more in new files
+		So(writeFiles(dir, map[string]string{
+			"main.go": `package main
+
+func main() {
+}
+
+func helper() {}
+
+// appended is added to a file git already tracks
+func appended() {}
+`,
+			"old.go": `package main
+
+// old was dead before the change
+func old() {}
+
+// stale was dead too, but the change edits it
+func stale() int {
+	return 2
+}
+`,
+			"added.go": `package main
+
+func added() {}
+`,
+			"with space.go": `package main
+
+func spaced() {}
+`,
+		}), ShouldBeNil)
+
+		ucf := NewUnusedCodeFinder()
+		ucf.Dir = dir
+		ucf.SSA = true
+
+		Convey("running 'ssa' without -since should find all the dead code", func() {
+			results, err := ucf.Run([]string{"./..."})
+			So(err, ShouldBeNil)
+			So("helper", ShouldBeFoundIn, results)
+			So("added", ShouldBeFoundIn, results)
+			So("appended", ShouldBeFoundIn, results)
+			So("old", ShouldBeFoundIn, results)
+		})
+
+		Convey("the changed lines should come from the diff and the untracked files", func() {
+			ucf.Since = "HEAD"
+			top, err := ucf.git("rev-parse", "--show-toplevel")
+			So(err, ShouldBeNil)
+			changed, err := ucf.changedLines(strings.TrimSpace(top))
+			So(err, ShouldBeNil)
+			So(changed["main.go"][8], ShouldBeTrue)
+			So(changed["main.go"][9], ShouldBeTrue)
+			So(changed["main.go"][6], ShouldBeFalse)
+			So(changed["old.go"][8], ShouldBeTrue)
+			So(changed["old.go"][4], ShouldBeFalse)
+			lines, ok := changed["with space.go"]
+			So(ok, ShouldBeTrue)
+			So(lines, ShouldBeNil)
+		})
+
+		Convey("running 'ssa' since HEAD", func() {
+			ucf.Since = "HEAD"
+			results, err := ucf.Run([]string{"./..."})
+			So(err, ShouldBeNil)
+
+			Convey("should find dead code in changed lines of tracked files", func() {
+				So("appended", ShouldBeFoundIn, results)
+				So("stale", ShouldBeFoundIn, results)
+			})
+
+			Convey("and in new files, whatever their names", func() {
+				So("added", ShouldBeFoundIn, results)
+				So("spaced", ShouldBeFoundIn, results)
+			})
+
+			Convey("and code the change made dead", func() {
+				So("helper", ShouldBeFoundIn, results)
+			})
+
+			Convey("but not code that was already dead in untouched lines", func() {
+				So("old", ShouldNotBeFoundIn, results)
+			})
+
+			Convey("and it should clean up its worktree", func() {
+				out, err := exec.Command("git", "-C", dir, "worktree", "list").Output()
+				So(err, ShouldBeNil)
+				So(strings.Count(string(out), "\n"), ShouldEqual, 1)
+			})
+		})
+	})
+}
@@ -0,0 +1,105 @@
+package unused
+
+import (
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// findUnusedWithSSA runs a single reachability-correct pass over every
+// kind of package-level declaration. Functions and methods are live if
+// the callgraph can reach them; everything else (vars, consts, types and
+// struct fields) is live only if a live declaration refers to it. This
+// means code that is only used by dead code is reported as well.
+func (ucf *UnusedCodeFinder) findUnusedWithSSA() ([]UnusedObject, error) {
+	ucf.Logf("Running callgraph analysis on following packages: \n\t%v",
+		strings.Join(ucf.pkgsAsArray(), "\n\t"))
+	if err := ucf.getCallgraph(); err != nil {
+		ucf.Errorf("Error running callgraph analysis: %v", err.Error())
+		return nil, err
+	}
+
+	ucf.Logf("Collecting declarations and references")
+	decls := ucf.collectDeclarationGraph()
+
+	ucf.Logf("Propagating liveness from reachable functions")
+	live := ucf.liveDeclarations(decls)
+
+	unused := []UnusedObject{}
+	for pos, d := range decls {
+		if skipDeclName(d.Obj.Name()) {
+			continue
+		}
+		ucf.declared = append(ucf.declared, ucf.declObject(d))
+		if !live[pos] && !ucf.isKept(pos) {
+			unused = append(unused, ucf.declObject(d))
+		}
+	}
+	return unused, nil
+}
+
+// liveDeclarations seeds the live set with every declared function the
+// callgraph reached, then follows references out of live declarations
+// until nothing changes.
+func (ucf *UnusedCodeFinder) liveDeclarations(
+	decls map[token.Position]*declaration) map[token.Position]bool {
+
+	live := map[token.Position]bool{}
+	queue := []*declaration{}
+	for fn := range ucf.reachable {
+		// a declared function's position is that of its name, which
+		// also covers generic instances and init functions
+		pos := ucf.fset.Position(fn.Pos())
+		if d, ok := decls[pos]; ok && !live[pos] {
+			live[pos] = true
+			queue = append(queue, d)
+		}
+	}
+	for pos := range ucf.keep {
+		if d, ok := decls[pos]; ok && !live[pos] {
+			live[pos] = true
+			queue = append(queue, d)
+		}
+	}
+	if ucf.Library {
+		// the exported API is used by definition, not just its functions
+		for pos, d := range decls {
+			if !live[pos] && !d.isFunc() && isExportedDecl(d.Obj) {
+				live[pos] = true
+				queue = append(queue, d)
+			}
+		}
+	}
+	// keep the walk deterministic for easier debugging
+	sort.Slice(queue, func(i, j int) bool {
+		return queue[i].Pos.String() < queue[j].Pos.String()
+	})
+
+	for len(queue) > 0 {
+		d := queue[0]
+		queue = queue[1:]
+		for _, ref := range d.Refs {
+			r, ok := decls[ref]
+			// the callgraph alone decides which functions are live
+			if !ok || live[ref] || r.isFunc() {
+				continue
+			}
+			live[ref] = true
+			queue = append(queue, r)
+		}
+	}
+	return live
+}
+
+// isExportedDecl reports whether obj is an exported package-level
+// declaration or an exported struct field
+func isExportedDecl(obj types.Object) bool {
+	if !obj.Exported() {
+		return false
+	}
+	if v, ok := obj.(*types.Var); ok && v.IsField() {
+		return true
+	}
+	return obj.Parent() == obj.Pkg().Scope()
+}
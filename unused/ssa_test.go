@@ -0,0 +1,77 @@
+package unused
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestUnusedWithSSA(t *testing.T) {
+	Convey("with a test main package and a UnusedCodeFinder in ssa mode", t, func() {
+		ucf := NewUnusedCodeFinder()
+		So(ucf, ShouldNotBeNil)
+		ucf.SSA = true
+
+		Convey("running 'ssa'", func() {
+			results, err := ucf.Run(testdataPkgs)
+			So(err, ShouldBeNil)
+
+			Convey("unreachable functions should be found, including transitively dead ones", func() {
+				So("oldHelper", ShouldBeFoundIn, results)
+				So("GenSix", ShouldBeFoundIn, results)
+				So("GenUInt", ShouldBeFoundIn, results)
+				So("toUint", ShouldBeFoundIn, results)
+				So("GrayKittenLink", ShouldBeFoundIn, results)
+				So("(unusedType).Val", ShouldBeFoundIn, results)
+			})
+
+			Convey("as well as every other kind of declaration only dead code uses", func() {
+				So("Number", ShouldBeFoundIn, results)
+				So("AnotherNumber", ShouldBeFoundIn, results)
+				So("Six", ShouldBeFoundIn, results)
+				So("unusedType", ShouldBeFoundIn, results)
+				So("field", ShouldBeFoundIn, results)
+			})
+
+			Convey("but nothing live should be found", func() {
+				So("GenInt", ShouldNotBeFoundIn, results)
+				So("GenIntMod400", ShouldNotBeFoundIn, results)
+				So("ColorKittenLink", ShouldNotBeFoundIn, results)
+				So("init", ShouldNotBeFoundIn, results)
+				So("main", ShouldNotBeFoundIn, results)
+			})
+		})
+
+		Convey("running 'ssa' with -tests", func() {
+			ucf.IncludeTests = true
+			results, err := ucf.Run(testdataPkgs)
+			So(err, ShouldBeNil)
+
+			Convey("code used by tests should be live", func() {
+				So("GenSix", ShouldNotBeFoundIn, results)
+				So("Six", ShouldNotBeFoundIn, results)
+			})
+
+			Convey("but unused helpers in test files should be found", func() {
+				So("testhelper", ShouldBeFoundIn, results)
+			})
+		})
+
+		Convey("running 'ssa' in library mode on a package without main", func() {
+			ucf.Library = true
+			results, err := ucf.Run([]string{"./testdata/pkg1"})
+			So(err, ShouldBeNil)
+
+			Convey("the exported API and what it uses should be live", func() {
+				So("GenSix", ShouldNotBeFoundIn, results)
+				So("Six", ShouldNotBeFoundIn, results)
+				So("Number", ShouldNotBeFoundIn, results)
+				So("toUint", ShouldNotBeFoundIn, results)
+			})
+
+			Convey("but unexported code nothing reaches should be found", func() {
+				So("isEven", ShouldBeFoundIn, results)
+				So("isOdd", ShouldBeFoundIn, results)
+			})
+		})
+	})
+}
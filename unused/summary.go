@@ -0,0 +1,98 @@
+package unused
+
+import (
+	"sort"
+)
+
+// PackageSummary counts the dead code found in a single package
+type PackageSummary struct {
+	Package string
+	// Dead counts the unused objects of each kind
+	Dead map[string]int
+	// DeadDecls and Decls are the number of dead declarations and of
+	// all declarations the analysis looked at
+	DeadDecls int
+	Decls     int
+	// DeadLines is the number of source lines the dead declarations
+	// span, out of the Lines of every analyzed file in the package
+	DeadLines int
+	Lines     int
+}
+
+// DeadPercent returns the percentage of the package's lines that are dead
+func (s PackageSummary) DeadPercent() float64 {
+	if s.Lines == 0 {
+		return 0
+	}
+	return 100 * float64(s.DeadLines) / float64(s.Lines)
+}
+
+// Summarize groups the results of Run by package. The packages with
+// the most dead lines come first, then those with the most dead
+// declarations.
+func (ucf *UnusedCodeFinder) Summarize(unusedObjects []UnusedObject) []PackageSummary {
+	byPackage := map[string]*PackageSummary{}
+	summaryOf := func(pkg string) *PackageSummary {
+		s, ok := byPackage[pkg]
+		if !ok {
+			s = &PackageSummary{Package: pkg, Dead: map[string]int{}}
+			byPackage[pkg] = s
+		}
+		return s
+	}
+	for pkg, files := range ucf.fileLines {
+		s := summaryOf(pkg)
+		for _, lines := range files {
+			s.Lines += lines
+		}
+	}
+	for _, o := range ucf.declared {
+		summaryOf(o.Package).Decls++
+	}
+	dead := map[string][]UnusedObject{}
+	for _, o := range unusedObjects {
+		s := summaryOf(o.Package)
+		s.Dead[o.Kind]++
+		s.DeadDecls++
+		dead[o.Package] = append(dead[o.Package], o)
+	}
+	for pkg, objs := range dead {
+		byPackage[pkg].DeadLines = lineCount(objs)
+	}
+
+	summaries := make([]PackageSummary, 0, len(byPackage))
+	for _, s := range byPackage {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if a.DeadLines != b.DeadLines {
+			return a.DeadLines > b.DeadLines
+		}
+		if a.DeadDecls != b.DeadDecls {
+			return a.DeadDecls > b.DeadDecls
+		}
+		return a.Package < b.Package
+	})
+	return summaries
+}
+
+// lineCount returns the number of source lines the objects span. A dead
+// type and its dead fields overlap, so each line is only counted once.
+func lineCount(unusedObjects []UnusedObject) int {
+	lines := map[string]map[int]bool{}
+	for _, o := range unusedObjects {
+		file := o.Position.Filename
+		if lines[file] == nil {
+			lines[file] = map[int]bool{}
+		}
+		for line := o.Start.Line; line <= o.End.Line; line++ {
+			lines[file][line] = true
+		}
+	}
+	count := 0
+	for _, fileLines := range lines {
+		count += len(fileLines)
+	}
+	return count
+}
@@ -0,0 +1,45 @@
+package unused
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestSummary(t *testing.T) {
+	Convey("with a test main package and a UnusedCodeFinder running 'ssa'", t, func() {
+		ucf := NewUnusedCodeFinder()
+		ucf.SSA = true
+		results, err := ucf.Run(testdataPkgs)
+		So(err, ShouldBeNil)
+
+		Convey("the summary should have a row for every analyzed package", func() {
+			summaries := ucf.Summarize(results)
+			byPackage := map[string]PackageSummary{}
+			for _, s := range summaries {
+				byPackage[s.Package] = s
+			}
+			So(len(summaries), ShouldEqual, len(ucf.Packages()))
+
+			Convey("counting dead declarations by kind out of every declaration", func() {
+				s := byPackage["github.com/3rf/codecoroner/unused/testdata/pkg2"]
+				So(s.Dead[KindType], ShouldBeGreaterThan, 0)
+				So(s.Dead[KindField], ShouldBeGreaterThan, 0)
+				sum := 0
+				for _, n := range s.Dead {
+					sum += n
+				}
+				So(sum, ShouldEqual, s.DeadDecls)
+				So(s.Decls, ShouldBeGreaterThan, s.DeadDecls)
+				So(s.DeadLines, ShouldBeGreaterThan, 0)
+				So(s.Lines, ShouldBeGreaterThan, s.DeadLines)
+				So(s.DeadPercent(), ShouldBeBetween, 0, 100)
+			})
+
+			Convey("with the packages with the most dead lines first", func() {
+				for i := 1; i < len(summaries); i++ {
+					So(summaries[i-1].DeadLines, ShouldBeGreaterThanOrEqualTo, summaries[i].DeadLines)
+				}
+			})
+		})
+	})
+}
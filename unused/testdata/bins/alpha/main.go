@@ -0,0 +1,17 @@
+// alpha is the old binary, which is about to be deprecated
+package main
+
+import (
+	"fmt"
+	"github.com/3rf/codecoroner/unused/testdata/bins/shared"
+)
+
+func main() {
+	fmt.Println(banner(), shared.Greet("alpha"), shared.Legacy())
+	fmt.Println(shared.Announce(shared.Loud{}))
+}
+
+// banner is only used by alpha's main, so it goes away with alpha
+func banner() string {
+	return "== alpha =="
+}
@@ -0,0 +1,12 @@
+// beta is the new binary
+package main
+
+import (
+	"fmt"
+	"github.com/3rf/codecoroner/unused/testdata/bins/shared"
+)
+
+func main() {
+	fmt.Println(shared.Greet("beta"), shared.Fresh())
+	fmt.Println(shared.Announce(shared.Quiet{}))
+}
@@ -0,0 +1,51 @@
+// Package shared is used by two binaries, to test per-binary reachability.
+package shared
+
+// Greet is used by both binaries
+func Greet(name string) string {
+	return "hello, " + name + salutation()
+}
+
+func salutation() string {
+	return "!"
+}
+
+// Legacy is only used by the old alpha binary
+func Legacy() string {
+	return "the old way"
+}
+
+// Fresh is only used by the new beta binary
+func Fresh() string {
+	return "the new way"
+}
+
+// Unused isn't used by either binary
+func Unused() string {
+	return "nothing"
+}
+
+// Speaker is implemented by a different type in each binary
+type Speaker interface {
+	Speak() string
+}
+
+// Announce is used by both binaries, but each only passes it its own
+// Speaker, so each Speak method is only reached by one binary
+func Announce(s Speaker) string {
+	return s.Speak()
+}
+
+// Loud is only created by alpha
+type Loud struct{}
+
+func (Loud) Speak() string {
+	return "HELLO"
+}
+
+// Quiet is only created by beta
+type Quiet struct{}
+
+func (Quiet) Speak() string {
+	return "hello"
+}
@@ -23,4 +23,7 @@ func main() {
 
 	fmt.Println("Here are some random numbers:", pkg1.GenInt(), pkg1.GenInt(), pkg1.GenInt())
 	fmt.Println("And here is a link to a picture of a cat:", pkg2.ColorKittenLink())
+	fmt.Println("And here is what it says:", pkg2.Tabby{}.Meow())
+	fmt.Println("And what it does:", pkg2.Purr(2), pkg2.Pounce())
+	fmt.Println("And how many there are:", pkg2.Litter(4))
 }
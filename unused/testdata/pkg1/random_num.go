@@ -12,7 +12,7 @@ const Number = 5
 // This var should be found by [idents]
 var AnotherNumber = 7
 
-// This should not be found by any mode.
+// Only the dead GenSix uses this, so just [ssa] without tests finds it.
 var Six = 6
 
 // This function is used, so it should not be found by any mode.
@@ -26,8 +26,8 @@ func GenIntMod400() int {
 	return GenInt() % 400
 }
 
-// This function should be found by [funcs] but not [idents], since
-// it is called by GenUInt, which is a dead function.
+// This function should be found by every mode, since it is only
+// called by GenUInt, which is a dead function.
 func toUint(i int) uint {
 	return uint(i)
 }
@@ -42,3 +42,18 @@ func GenUInt() uint {
 func GenSix() int {
 	return Six
 }
+
+// These functions only call each other, so every mode should find both.
+func isEven(n int) bool {
+	if n == 0 {
+		return true
+	}
+	return isOdd(n - 1)
+}
+
+func isOdd(n int) bool {
+	if n == 0 {
+		return false
+	}
+	return isEven(n - 1)
+}
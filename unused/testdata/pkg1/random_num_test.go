@@ -1,6 +1,7 @@
 package pkg1
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -16,3 +17,34 @@ func TestTheNumberSix(t *testing.T) {
 		t.Fatal("THIS HAS GONE POORLY")
 	}
 }
+
+// Benchmarks, examples and fuzz tests should not be found either,
+// and neither should the helpers only they call
+func BenchmarkGenInt(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchhelper()
+	}
+}
+
+func benchhelper() int {
+	return GenInt()
+}
+
+func ExampleGenSix() {
+	fmt.Println(examplehelper())
+	// Output: 6
+}
+
+func examplehelper() int {
+	return 6
+}
+
+func FuzzGenIntMod400(f *testing.F) {
+	f.Fuzz(func(t *testing.T, n int) {
+		fuzzhelper(n)
+	})
+}
+
+func fuzzhelper(n int) int {
+	return n % 400
+}
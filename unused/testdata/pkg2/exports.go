@@ -0,0 +1,27 @@
+package pkg2
+
+import _ "unsafe" // for go:linkname
+
+// knead is called from C through cgo.
+//
+//export knead
+func knead() int {
+	return 2
+}
+
+// nap is called by the wasm host.
+//
+//go:wasmexport nap
+func nap() {}
+
+// stretch is pulled into the runtime by name.
+//
+//go:linkname stretch
+func stretch() int {
+	return 3
+}
+
+// shed really is dead, since it's not exported to anyone
+func shed() int {
+	return 4
+}
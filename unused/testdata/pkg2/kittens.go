@@ -5,6 +5,7 @@ package pkg2
 import (
 	"fmt"
 	"github.com/3rf/codecoroner/unused/testdata/pkg1"
+	"strings"
 )
 
 // this type and its method should be found by [idents]
@@ -27,3 +28,50 @@ func GrayKittenLink() string {
 		pkg1.GenIntMod400()+400,
 		pkg1.GenIntMod400()+200)
 }
+
+// Both kitten types have a Meow method in this file, but only the
+// Tabby's is called, so [funcs] should find just (calico).Meow
+type Tabby struct{}
+type calico struct{}
+
+func (Tabby) Meow() string {
+	return "mrrp"
+}
+
+func (calico) Meow() string {
+	return "mew"
+}
+
+// This func-valued var is called, so it should not be found.
+var Purr = func(times int) string {
+	return strings.Repeat("purr", times)
+}
+
+// This one is never called, so [funcs] should find it.
+var hiss = func(loud bool) string {
+	if loud {
+		return "HISS"
+	}
+	return "hiss"
+}
+
+// This function is used, but the second closure it builds is never
+// called, so [funcs] should find Pounce$2 (and not Pounce$1).
+func Pounce() string {
+	leap := func(height int) string {
+		return fmt.Sprintf("leaps %v feet", height)
+	}
+	nap := func(minutes uint8) string {
+		return fmt.Sprintf("naps for %v minutes", minutes)
+	}
+	_ = nap
+	return leap(3)
+}
+
+// Litter is only ever printed, so its String method is called through
+// fmt.Stringer and should not be found by [idents] or [funcs]
+type Litter int
+
+func (l Litter) String() string {
+	return fmt.Sprintf("%d kittens", int(l))
+}
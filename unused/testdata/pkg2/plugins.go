@@ -0,0 +1,37 @@
+package pkg2
+
+// Scratch is looked up by name with plugin.Lookup, so nothing calls it.
+//
+//codecoroner:keep
+func Scratch() string {
+	return groom()
+}
+
+// groom is only kept alive by Scratch
+func groom() string {
+	return "groomed"
+}
+
+// handlers are looked up by name through reflection.
+//
+//codecoroner:keep
+var handlers = map[string]func() string{
+	"hide": hide,
+	"sulk": func() string { return "sulking" },
+}
+
+func hide() string {
+	return "under the couch"
+}
+
+// Whiskers are counted by a reflection-based decoder
+type Whiskers struct {
+	Count int //codecoroner:keep
+	// Color is never read
+	Color string
+}
+
+// Yowl is listed in the testdata roots file
+func Yowl() string {
+	return "YOWL"
+}
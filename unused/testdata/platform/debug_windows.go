@@ -0,0 +1,7 @@
+//go:build debug
+
+package main
+
+// This function only exists in debug builds for windows, so it should
+// be found without a label
+func windowsDebugOnly() {}
@@ -0,0 +1,18 @@
+// A small main package for testing codecoroner's build matrix analysis.
+// This code is test code for codecoroner analysis--do not actually use it.
+package main
+
+// This function is only called on linux, so it should be labeled
+func linuxOnly() string {
+	return "penguin"
+}
+
+// This function is only called on darwin, so it should be labeled
+func darwinOnly() string {
+	return "apple"
+}
+
+// This function is never called, so it should be found in every configuration
+func deadEverywhere() string {
+	return "nobody"
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"example.com/libs/strs"
+)
+
+// this function should be found in the cmd module
+func unusedFlag() bool {
+	return false
+}
+
+func main() {
+	for _, arg := range os.Args[1:] {
+		fmt.Println(strs.Shout(arg))
+	}
+}
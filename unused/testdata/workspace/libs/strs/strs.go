@@ -0,0 +1,16 @@
+// A small library module for testing codecoroner's workspace mode.
+// This code is test code for codecoroner analysis--do not actually use it.
+package strs
+
+import "strings"
+
+// This function is only used by a binary in another module,
+// so it should not be found in workspace mode.
+func Shout(s string) string {
+	return strings.ToUpper(s) + "!"
+}
+
+// This function isn't used by any module, so it should be found.
+func Whisper(s string) string {
+	return strings.ToLower(s) + "..."
+}
@@ -0,0 +1,161 @@
+package unused
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// LiveEdge is one call on the path that keeps a function alive
+type LiveEdge struct {
+	Caller   string
+	Callee   string
+	Position token.Position // of the call site, if there is one
+	// Dynamic is set for calls through an interface or a function value
+	Dynamic bool
+
+	file string // the shortened filename of Position
+}
+
+func (e LiveEdge) String() string {
+	s := fmt.Sprintf("%v -> %v", e.Caller, e.Callee)
+	if e.Position.IsValid() {
+		file := e.file
+		if file == "" {
+			file = trimGopath(e.Position.Filename)
+		}
+		s = fmt.Sprintf("%v:%v: %v", file, e.Position.Line, s)
+	}
+	if e.Dynamic {
+		s += " (dynamic dispatch)"
+	}
+	return s
+}
+
+// WhyLive explains why the function named by symbol is reachable, by
+// returning the shortest call chain from a root (main, init, a test main
+// or any other configured root) to it. The symbol can be given the way
+// 'funcs' prints it, like "toUint" or "(Tabby).Meow", or qualified with
+// its package like "pkg1.toUint" or "(*pkg.T).Method". An empty chain
+// means the function is a root itself.
+func (ucf *UnusedCodeFinder) WhyLive(symbol string, patterns []string) ([]LiveEdge, error) {
+	if err := ucf.load(patterns); err != nil {
+		return nil, err
+	}
+	ucf.Logf("Running callgraph analysis on following packages: \n\t%v",
+		strings.Join(ucf.pkgsAsArray(), "\n\t"))
+	if err := ucf.getCallgraph(); err != nil {
+		return nil, err
+	}
+
+	names := ucf.funcDeclNames()
+
+	// breadth-first from every root at once finds the shortest chain
+	via := map[*callgraph.Node]*callgraph.Edge{}
+	seen := map[*callgraph.Node]bool{}
+	queue := []*callgraph.Node{}
+	for _, root := range ucf.roots {
+		if node := ucf.callgraph.Nodes[root]; node != nil && !seen[node] {
+			seen[node] = true
+			queue = append(queue, node)
+		}
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if ucf.isFuncNamed(node.Func, symbol, names) {
+			return ucf.liveChain(node, via, names), nil
+		}
+		for _, edge := range node.Out {
+			if !seen[edge.Callee] {
+				seen[edge.Callee] = true
+				via[edge.Callee] = edge
+				queue = append(queue, edge.Callee)
+			}
+		}
+	}
+
+	// tell a dead function apart from a misspelled one
+	for _, f := range ucf.funcs {
+		if f.Name == symbol || strings.HasSuffix(symbol, "."+f.Name) {
+			return nil, fmt.Errorf("'%v' is not reachable from any root", symbol)
+		}
+	}
+	return nil, fmt.Errorf("no function named '%v' was found", symbol)
+}
+
+// liveChain follows the recorded edges back from node to its root
+func (ucf *UnusedCodeFinder) liveChain(node *callgraph.Node,
+	via map[*callgraph.Node]*callgraph.Edge, names map[token.Position]string) []LiveEdge {
+
+	chain := []LiveEdge{}
+	for edge := via[node]; edge != nil; edge = via[edge.Caller] {
+		e := LiveEdge{
+			Caller: ucf.displayName(edge.Caller.Func, names),
+			Callee: ucf.displayName(edge.Callee.Func, names),
+		}
+		if edge.Site != nil {
+			e.Position = ucf.fset.Position(edge.Pos())
+			e.file = ucf.displayPath(e.Position.Filename)
+			e.Dynamic = edge.Site.Common().StaticCallee() == nil
+		}
+		chain = append([]LiveEdge{e}, chain...)
+	}
+	return chain
+}
+
+// funcDeclNames maps the key of every funcDecl to its reported name
+func (ucf *UnusedCodeFinder) funcDeclNames() map[token.Position]string {
+	names := map[token.Position]string{}
+	for _, f := range ucf.funcs {
+		names[f.key] = f.Name
+	}
+	return names
+}
+
+// isFuncNamed reports whether symbol is one of the names of fn
+func (ucf *UnusedCodeFinder) isFuncNamed(fn *ssa.Function, symbol string,
+	names map[token.Position]string) bool {
+
+	for _, name := range ucf.funcNames(fn, names) {
+		if name == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// funcNames returns every name a function can be asked for by: its ssa
+// name, and the name 'funcs' reports it under, each both unqualified and
+// qualified by package name or import path
+func (ucf *UnusedCodeFinder) funcNames(fn *ssa.Function, names map[token.Position]string) []string {
+	if fn.Pkg == nil {
+		return nil
+	}
+	path, pkgName := fn.Pkg.Pkg.Path(), fn.Pkg.Pkg.Name()
+	full := fn.String()
+	result := []string{full, strings.ReplaceAll(full, path+".", pkgName+".")}
+	if key, ok := ucf.funcKey(fn); ok {
+		if name, ok := names[key]; ok {
+			result = append(result, name, pkgName+"."+name, path+"."+name)
+		}
+	}
+	return result
+}
+
+// displayName is the package-qualified name of a function, preferring
+// the variable name 'funcs' gives to function literals
+func (ucf *UnusedCodeFinder) displayName(fn *ssa.Function, names map[token.Position]string) string {
+	if fn.Pkg == nil {
+		return fn.String()
+	}
+	if key, ok := ucf.funcKey(fn); ok && fn.Parent() != nil {
+		if name, ok := names[key]; ok {
+			return fn.Pkg.Pkg.Name() + "." + name
+		}
+	}
+	return strings.ReplaceAll(fn.String(), fn.Pkg.Pkg.Path()+".", fn.Pkg.Pkg.Name()+".")
+}
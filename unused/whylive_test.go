@@ -0,0 +1,59 @@
+package unused
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestWhyLive(t *testing.T) {
+	Convey("with a test main package and a UnusedCodeFinder", t, func() {
+		ucf := NewUnusedCodeFinder()
+		So(ucf, ShouldNotBeNil)
+
+		Convey("asking about a function called directly by main", func() {
+			chain, err := ucf.WhyLive("GenInt", testdataPkgs)
+			So(err, ShouldBeNil)
+			So(len(chain), ShouldEqual, 1)
+			So(chain[0].Caller, ShouldEqual, "main.main")
+			So(chain[0].Callee, ShouldEqual, "pkg1.GenInt")
+			So(chain[0].Position.Line, ShouldEqual, 24)
+			So(chain[0].Dynamic, ShouldBeFalse)
+		})
+
+		Convey("asking about a function deeper in the callgraph", func() {
+			chain, err := ucf.WhyLive("pkg1.GenIntMod400", testdataPkgs)
+			So(err, ShouldBeNil)
+			So(len(chain), ShouldEqual, 2)
+			So(chain[0].Callee, ShouldEqual, "pkg2.ColorKittenLink")
+			So(chain[1].Caller, ShouldEqual, "pkg2.ColorKittenLink")
+			So(chain[1].Callee, ShouldEqual, "pkg1.GenIntMod400")
+		})
+
+		Convey("asking about a function literal called through a variable", func() {
+			chain, err := ucf.WhyLive("Purr", testdataPkgs)
+			So(err, ShouldBeNil)
+			So(len(chain), ShouldEqual, 1)
+			So(chain[0].Callee, ShouldEqual, "pkg2.Purr")
+			So(chain[0].Dynamic, ShouldBeTrue)
+			So(chain[0].String(), ShouldEndWith, "(dynamic dispatch)")
+		})
+
+		Convey("asking about a root", func() {
+			chain, err := ucf.WhyLive("main.main", testdataPkgs)
+			So(err, ShouldBeNil)
+			So(chain, ShouldBeEmpty)
+		})
+
+		Convey("asking about a dead function", func() {
+			_, err := ucf.WhyLive("oldHelper", testdataPkgs)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "not reachable")
+		})
+
+		Convey("asking about a function that doesn't exist", func() {
+			_, err := ucf.WhyLive("noSuchFunc", testdataPkgs)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "no function named")
+		})
+	})
+}
@@ -0,0 +1,79 @@
+package unused
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+)
+
+// findWorkspace walks up from dir looking for a go.work file, the same
+// way the go tool does, and returns its path.
+func findWorkspace(dir string) (string, error) {
+	if gowork := os.Getenv("GOWORK"); gowork != "" {
+		if gowork == "off" {
+			return "", fmt.Errorf("workspace mode disabled by GOWORK=off")
+		}
+		return gowork, nil
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		workFile := filepath.Join(dir, "go.work")
+		if _, err := os.Stat(workFile); err == nil {
+			return workFile, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no go.work file found")
+		}
+		dir = parent
+	}
+}
+
+// workspacePatterns returns a "module/..." pattern for every module
+// used by the go.work file that governs the finder's directory
+func (ucf *UnusedCodeFinder) workspacePatterns() ([]string, error) {
+	dir := ucf.Dir
+	if dir == "" {
+		dir = "."
+	}
+	workFile, err := findWorkspace(dir)
+	if err != nil {
+		return nil, err
+	}
+	if workFile, err = filepath.Abs(workFile); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(workFile)
+	if err != nil {
+		return nil, err
+	}
+	wf, err := modfile.ParseWork(workFile, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	ucf.Logf("Using workspace %v", workFile)
+
+	patterns := []string{}
+	for _, use := range wf.Use {
+		modDir := use.Path
+		if !filepath.IsAbs(modDir) {
+			modDir = filepath.Join(filepath.Dir(workFile), modDir)
+		}
+		root, modPath, ok := findModule(modDir)
+		if !ok || root != filepath.Clean(modDir) {
+			return nil, fmt.Errorf("no go.mod found for workspace module %q", use.Path)
+		}
+		ucf.Logf("Found workspace module %v", modPath)
+		patterns = append(patterns, modPath+"/...")
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("%v does not use any modules", workFile)
+	}
+	return patterns, nil
+}
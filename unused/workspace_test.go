@@ -0,0 +1,45 @@
+package unused
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestUnusedFuncsInWorkspace(t *testing.T) {
+	Convey("with a go.work workspace and a UnusedCodeFinder in workspace mode", t, func() {
+		ucf := NewUnusedCodeFinder()
+		So(ucf, ShouldNotBeNil)
+		ucf.Dir = "testdata/workspace"
+		ucf.Workspace = true
+
+		Convey("running 'funcs' with no patterns", func() {
+			results, err := ucf.Run(nil)
+			So(err, ShouldBeNil)
+
+			Convey("code used from a sibling module should not be found", func() {
+				So("Shout", ShouldNotBeFoundIn, results)
+				So("Whisper", ShouldBeFoundIn, results)
+				So("unusedFlag", ShouldBeFoundIn, results)
+			})
+
+			Convey("and each result should be tagged with its module", func() {
+				for _, o := range results {
+					switch o.Name {
+					case "Whisper":
+						So(o.Module, ShouldEqual, "example.com/libs")
+					case "unusedFlag":
+						So(o.Module, ShouldEqual, "example.com/cmd")
+					}
+				}
+			})
+		})
+
+		Convey("running 'idents' should also see across modules", func() {
+			ucf.Idents = true
+			results, err := ucf.Run(nil)
+			So(err, ShouldBeNil)
+			So("Shout", ShouldNotBeFoundIn, results)
+			So("Whisper", ShouldBeFoundIn, results)
+		})
+	})
+}